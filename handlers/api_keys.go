@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(key database.ApiKey) apiKeyResponse {
+	res := apiKeyResponse{
+		ID:        key.ID,
+		CreatedAt: key.CreatedAt,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+	}
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return res
+}
+
+// createAPIKey creates a personal API key. The key itself is only ever shown
+// in this response.
+func createAPIKey(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := apiKeyRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Name is required", nil)
+			return
+		}
+
+		if len(body.Scopes) == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+			return
+		}
+		for _, scope := range body.Scopes {
+			if !auth.ValidScope(scope) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Unknown scope: "+scope, nil)
+				return
+			}
+		}
+
+		key, prefix, err := auth.MakeAPIKey()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not make API key", err)
+			return
+		}
+
+		saved, err := cfg.db.CreateAPIKey(req.Context(), database.CreateAPIKeyParams{
+			UserID:  userIDFromContext(req.Context()),
+			Name:    body.Name,
+			Prefix:  prefix,
+			KeyHash: auth.HashToken(key),
+			Scopes:  body.Scopes,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not save API key", err)
+			return
+		}
+
+		res := newAPIKeyResponse(saved)
+		res.Key = key
+		utils.RespondWithJSON(w, http.StatusCreated, res)
+	}
+}
+
+func listAPIKeys(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		keys, err := cfg.db.ListUserAPIKeys(req.Context(), userIDFromContext(req.Context()))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not list API keys", err)
+			return
+		}
+
+		res := make([]apiKeyResponse, 0, len(keys))
+		for _, key := range keys {
+			res = append(res, newAPIKeyResponse(key))
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, res)
+	}
+}
+
+func revokeAPIKey(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		keyID, err := uuid.Parse(req.PathValue("keyID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid key ID", err)
+			return
+		}
+
+		revoked, err := cfg.db.RevokeAPIKey(req.Context(), database.RevokeAPIKeyParams{
+			ID:     keyID,
+			UserID: userIDFromContext(req.Context()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke API key", err)
+			return
+		}
+
+		if revoked == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "API key not found", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
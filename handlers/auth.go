@@ -1,17 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
 	"github.com/khizar-sudo/chirpy/internal/utils"
 )
 
+const refreshTokenExpiry = 60 * 24 * time.Hour
+
 type loginRequest struct {
 	Email            string `json:"email"`
 	Password         string `json:"password"`
@@ -19,11 +24,20 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `json:"email"`
-	Token     string    `json:"token"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+type refreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 func login(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
@@ -41,18 +55,30 @@ func login(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		// Throttle before looking at the password so that guessing is slow
+		// for the attacker and cheap for us. The attempt is counted as failed
+		// up front so that parallel guesses cannot get past the backoff.
+		throttleKeys := loginThrottleKeys(body.Email, req)
+		if !cfg.reserveLoginThrottle(w, req, throttleKeys) {
+			return
+		}
+
 		user, err := cfg.db.GetUser(req.Context(), body.Email)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				utils.RespondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
 			} else {
+				cfg.releaseLoginAttempt(req.Context(), throttleKeys)
 				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
 			}
 			return
 		}
 
 		ok, err := auth.CheckPasswordHash(body.Password, user.HashedPassword)
-		if err != nil {
+		// Accounts without a password fail like a wrong password so that
+		// they cannot be told apart.
+		if err != nil && !errors.Is(err, auth.ErrPasswordUnset) {
+			cfg.releaseLoginAttempt(req.Context(), throttleKeys)
 			utils.RespondWithError(w, http.StatusInternalServerError, "Error verifying password", err)
 			return
 		}
@@ -62,6 +88,26 @@ func login(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		cfg.loginSucceeded(req.Context(), throttleKeys)
+
+		if auth.NeedsRehash(user.HashedPassword) {
+			cfg.rehashPassword(req.Context(), user, body.Password)
+		}
+
+		if user.TotpEnabledAt.Valid {
+			mfaToken, err := cfg.tokenKeys.MakeMFAToken(user.ID, mfaTokenExpiry)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make token", err)
+				return
+			}
+
+			utils.RespondWithJSON(w, http.StatusOK, mfaRequiredResponse{
+				MFARequired: true,
+				MFAToken:    mfaToken,
+			})
+			return
+		}
+
 		var expiresIn time.Duration
 		if body.ExpiresInSeconds == nil || *body.ExpiresInSeconds > 3600 {
 			expiresIn = time.Hour
@@ -69,18 +115,198 @@ func login(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			expiresIn = time.Second * time.Duration(*body.ExpiresInSeconds)
 		}
 
-		token, err := auth.MakeJWT(user.ID, cfg.tokenSecret, expiresIn)
+		cfg.respondWithLogin(w, req, user, expiresIn)
+	}
+}
+
+// rehashPassword upgrades a password hash made with weaker parameters. The
+// login goes ahead even if this fails, and nothing changes if the password
+// was changed since user was read.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err == nil {
+		err = cfg.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+			HashedPassword:    hashedPassword,
+			ID:                user.ID,
+			OldHashedPassword: user.HashedPassword,
+		})
+	}
+	if err != nil {
+		log.Printf("Could not rehash password of user %s: %s", user.ID, err)
+	}
+}
+
+// respondWithLogin starts a new session for a user who has fully
+// authenticated and issues an access token and a refresh token for it. The
+// session's ID doubles as the family of its refresh tokens.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, req *http.Request, user database.User, expiresIn time.Duration) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not make refresh token", err)
+		return
+	}
+
+	var session database.Session
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		var err error
+		session, err = q.CreateSession(req.Context(), database.CreateSessionParams{
+			UserID:    user.ID,
+			UserAgent: req.UserAgent(),
+			Ip:        clientIP(req),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+			TokenHash: auth.HashToken(refreshToken),
+			UserID:    user.ID,
+			FamilyID:  session.ID,
+			ExpiresAt: time.Now().UTC().Add(refreshTokenExpiry),
+		})
+		return err
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create session", err)
+		return
+	}
+
+	token, err := cfg.tokenKeys.MakeJWT(user.ID, user.Role, session.ID, expiresIn)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not make token", err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, loginResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Role:          user.Role,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	})
+}
+
+// refresh exchanges a refresh token for a new access token. The presented
+// refresh token is rotated: it is revoked and replaced by a new one in the
+// same family. Presenting a token that was already rotated means it has
+// leaked, so the whole family is revoked.
+func refresh(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token, err := auth.GetBearerToken(req.Header)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, authHeaderErrorMessage(err), err)
+			return
+		}
+
+		newToken, err := auth.MakeRefreshToken()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not make refresh token", err)
+			return
+		}
+
+		// The old token is only spent if its replacement is saved too.
+		tokenHash := auth.HashToken(token)
+		newTokenHash := auth.HashToken(newToken)
+		var rotated database.RefreshToken
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			var err error
+			rotated, err = q.RotateRefreshToken(req.Context(), database.RotateRefreshTokenParams{
+				TokenHash:  tokenHash,
+				ReplacedBy: sql.NullString{String: newTokenHash, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = q.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+				TokenHash: newTokenHash,
+				UserID:    rotated.UserID,
+				FamilyID:  rotated.FamilyID,
+				ExpiresAt: time.Now().UTC().Add(refreshTokenExpiry),
+			})
+			return err
+		})
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not save refresh token", err)
+				return
+			}
+
+			existing, err := cfg.db.GetRefreshToken(req.Context(), tokenHash)
+			if err == nil && existing.ReplacedBy.Valid {
+				if _, err := cfg.endSession(req.Context(), existing.UserID, existing.FamilyID); err != nil {
+					utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+					return
+				}
+			}
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+			return
+		}
+
+		// Look the user up again so that a change of role is picked up.
+		user, err := cfg.db.GetUserByID(req.Context(), rotated.UserID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		err = cfg.db.TouchSession(req.Context(), database.TouchSessionParams{
+			ID: rotated.FamilyID,
+			Ip: clientIP(req),
+		})
+		if err != nil {
+			log.Printf("Could not record use of session %s: %s", rotated.FamilyID, err)
+		}
+
+		accessToken, err := cfg.tokenKeys.MakeJWT(user.ID, user.Role, rotated.FamilyID, time.Hour)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Could not make token", err)
 			return
 		}
 
-		utils.RespondWithJSON(w, http.StatusOK, loginResponse{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			Token:     token,
+		utils.RespondWithJSON(w, http.StatusOK, refreshResponse{
+			Token:        accessToken,
+			RefreshToken: newToken,
 		})
 	}
 }
+
+// revoke logs a device out by ending the session of the presented refresh
+// token.
+func revoke(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token, err := auth.GetBearerToken(req.Header)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, authHeaderErrorMessage(err), err)
+			return
+		}
+
+		existing, err := cfg.db.GetRefreshToken(req.Context(), auth.HashToken(token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		if _, err := cfg.endSession(req.Context(), existing.UserID, existing.FamilyID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke token", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func jwks(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		utils.RespondWithJSON(w, http.StatusOK, cfg.tokenKeys.JWKS())
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khizar-sudo/chirpy/internal/auth"
+)
+
+func TestRefresh(t *testing.T) {
+	cfg := newTestConfig(t)
+	prefix := seedPrefix(t, cfg.sqlDB)
+
+	user, err := cfg.db.GetUserByID(context.Background(), seedUser(t, cfg.sqlDB, prefix+"user@example.com"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.respondWithLogin(rec, httptest.NewRequest(http.MethodPost, "/api/login", nil), user, time.Hour)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var login loginResponse
+	if err := json.NewDecoder(rec.Body).Decode(&login); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	refreshWith := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		refresh(cfg)(rec, req)
+		return rec
+	}
+
+	t.Run("stores only a hash of the token", func(t *testing.T) {
+		var plain, hashed int
+		err := cfg.sqlDB.QueryRow(`
+SELECT COUNT(*) FILTER (WHERE token_hash = $1), COUNT(*) FILTER (WHERE token_hash = $2)
+FROM refresh_tokens`, login.RefreshToken, auth.HashToken(login.RefreshToken)).Scan(&plain, &hashed)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if plain != 0 || hashed != 1 {
+			t.Errorf("expected only the hash to be stored, got %d plain and %d hashed", plain, hashed)
+		}
+	})
+
+	var rotated refreshResponse
+	t.Run("rotates the token", func(t *testing.T) {
+		rec := refreshWith(login.RefreshToken)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&rotated); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+			t.Errorf("expected a new refresh token, got %q", rotated.RefreshToken)
+		}
+		if _, err := cfg.tokenKeys.ParseAccessToken(rotated.Token); err != nil {
+			t.Errorf("expected a valid access token, got %v", err)
+		}
+	})
+
+	t.Run("reuse ends the session", func(t *testing.T) {
+		if rec := refreshWith(login.RefreshToken); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401 for a reused token, got %d", rec.Code)
+		}
+
+		if rec := refreshWith(rotated.RefreshToken); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 for the replacement after reuse, got %d", rec.Code)
+		}
+	})
+
+	t.Run("rejects unknown tokens", func(t *testing.T) {
+		if rec := refreshWith("not-a-token"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", rec.Code)
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -11,31 +13,207 @@ import (
 	"github.com/google/uuid"
 	"github.com/khizar-sudo/chirpy/internal/auth"
 	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/pagination"
 	"github.com/khizar-sudo/chirpy/internal/utils"
 )
 
 type chirpRequest struct {
-	Body string `json:"body"`
+	Body      string     `json:"body"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
 }
 
 type chirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to,omitempty"`
+	RootID    *uuid.UUID `json:"root_id,omitempty"`
+	LikeCount int64      `json:"like_count"`
+	// LikedByMe is only set when the caller is authenticated.
+	LikedByMe *bool `json:"liked_by_me,omitempty"`
+	// RepostOf and QuoteOf inline the chirp that was shared. Reposts have
+	// an empty body of their own.
+	RepostOf *chirpResponse `json:"repost_of,omitempty"`
+	QuoteOf  *chirpResponse `json:"quote_of,omitempty"`
 }
 
-func createChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
-	return func(w http.ResponseWriter, req *http.Request) {
-		token, err := auth.GetBearerToken(req.Header)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusUnauthorized, "No token provided", err)
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	res := chirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+	if chirp.ParentID.Valid {
+		res.InReplyTo = &chirp.ParentID.UUID
+	}
+	if chirp.RootID.Valid {
+		res.RootID = &chirp.RootID.UUID
+	}
+	return res
+}
+
+// chirpResponses adds like counts, whether the authenticated user liked each
+// chirp and the chirps that were reposted or quoted to a list of chirps.
+// Shared chirps are inlined one level deep; deleted ones are left out.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp) ([]chirpResponse, error) {
+	var sharedIDs []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.RepostOfID.Valid {
+			sharedIDs = append(sharedIDs, chirp.RepostOfID.UUID)
+		}
+		if chirp.QuoteOfID.Valid {
+			sharedIDs = append(sharedIDs, chirp.QuoteOfID.UUID)
 		}
+	}
 
-		userID, err := auth.ValidateJWT(token, cfg.tokenSecret)
+	var shared []database.Chirp
+	if len(sharedIDs) > 0 {
+		var err error
+		shared, err = cfg.db.GetChirpsByIDs(ctx, sharedIDs)
 		if err != nil {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
+			return nil, err
+		}
+	}
+
+	// The shared chirps go first so their likes are fetched in the same
+	// query.
+	all, err := cfg.withLikes(ctx, append(shared, chirps...))
+	if err != nil {
+		return nil, err
+	}
+
+	sharedByID := make(map[uuid.UUID]chirpResponse, len(shared))
+	for _, res := range all[:len(shared)] {
+		sharedByID[res.ID] = res
+	}
+
+	response := all[len(shared):]
+	for i, chirp := range chirps {
+		if res, ok := sharedByID[chirp.RepostOfID.UUID]; chirp.RepostOfID.Valid && ok {
+			response[i].RepostOf = &res
+		}
+		if res, ok := sharedByID[chirp.QuoteOfID.UUID]; chirp.QuoteOfID.Valid && ok {
+			response[i].QuoteOf = &res
+		}
+	}
+	return response, nil
+}
+
+// withLikes converts chirps to responses with their like counts and, if the
+// caller is authenticated, whether they liked them.
+func (cfg *apiConfig) withLikes(ctx context.Context, chirps []database.Chirp) ([]chirpResponse, error) {
+	viewerID := userIDFromContext(ctx)
+
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		ids[i] = chirp.ID
+	}
+
+	stats, err := cfg.db.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		UserID:   uuid.NullUUID{UUID: viewerID, Valid: viewerID != uuid.Nil},
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byChirp := make(map[uuid.UUID]database.GetChirpLikeStatsRow, len(stats))
+	for _, stat := range stats {
+		byChirp[stat.ChirpID] = stat
+	}
+
+	response := make([]chirpResponse, len(chirps))
+	for i, chirp := range chirps {
+		stat := byChirp[chirp.ID]
+		response[i] = newChirpResponse(chirp)
+		response[i].LikeCount = stat.LikeCount
+		if viewerID != uuid.Nil {
+			response[i].LikedByMe = &stat.LikedByMe
+		}
+	}
+	return response, nil
+}
+
+// sharedChirp looks up a chirp that is being replied to, quoted or reposted.
+// A repost stands for the chirp it shares, so that chirp is returned
+// instead. ok is false if the chirp does not exist or has been deleted.
+func (cfg *apiConfig) sharedChirp(ctx context.Context, id uuid.UUID) (chirp database.Chirp, ok bool, err error) {
+	chirp, err = cfg.db.GetChrip(ctx, id)
+	if err == nil && chirp.RepostOfID.Valid {
+		chirp, err = cfg.db.GetChrip(ctx, chirp.RepostOfID.UUID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, false, nil
+	}
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	return chirp, !chirp.DeletedAt.Valid, nil
+}
+
+// findSharedChirp is sharedChirp for handlers. It responds with 404 and
+// returns false if there is no chirp to share.
+func (cfg *apiConfig) findSharedChirp(w http.ResponseWriter, req *http.Request, id uuid.UUID) (database.Chirp, bool) {
+	chirp, ok, err := cfg.sharedChirp(req.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+		return database.Chirp{}, false
+	}
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// originalChirpID returns the ID of the chirp a repost shares, or id itself
+// if it is not a repost. Unlike sharedChirp it does not mind chirps that are
+// gone, so likes and reposts of them can still be taken back.
+func (cfg *apiConfig) originalChirpID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	chirp, err := cfg.db.GetChrip(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if chirp.RepostOfID.Valid {
+		return chirp.RepostOfID.UUID, nil
+	}
+	return id, nil
+}
+
+// checkCanPost stops users from posting until they have verified their
+// email, if that is required. It responds and returns false if they may
+// not.
+func (cfg *apiConfig) checkCanPost(w http.ResponseWriter, req *http.Request, userID uuid.UUID) bool {
+	if !cfg.requireVerifiedEmail {
+		return true
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		utils.RespondWithError(w, http.StatusForbidden, "Verify your email before posting chirps", nil)
+		return false
+	}
+	return true
+}
+
+func createChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID := userIDFromContext(req.Context())
+
+		if !cfg.checkCanPost(w, req, userID) {
+			return
 		}
 
 		decoder := json.NewDecoder(req.Body)
@@ -51,11 +229,48 @@ func createChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request)
 			return
 		}
 
+		// Only the chirp's own text counts; a quoted chirp is not part of it.
 		if len(body.Body) > 140 {
 			utils.RespondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
 			return
 		}
 
+		// Replies remember the chirp that started their conversation so a
+		// thread can be found from any of its chirps.
+		parentID := uuid.NullUUID{}
+		rootID := uuid.NullUUID{}
+		if body.InReplyTo != nil {
+			parent, ok, err := cfg.sharedChirp(req.Context(), *body.InReplyTo)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+				return
+			}
+			if !ok {
+				utils.RespondWithError(w, http.StatusBadRequest, "The chirp being replied to does not exist", nil)
+				return
+			}
+
+			parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			rootID = parent.RootID
+			if !rootID.Valid {
+				rootID = parentID
+			}
+		}
+
+		quoteOfID := uuid.NullUUID{}
+		if body.QuoteOf != nil {
+			quoted, ok, err := cfg.sharedChirp(req.Context(), *body.QuoteOf)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+				return
+			}
+			if !ok {
+				utils.RespondWithError(w, http.StatusBadRequest, "The chirp being quoted does not exist", nil)
+				return
+			}
+			quoteOfID = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		}
+
 		words := strings.Split(body.Body, " ")
 		for i, word := range words {
 			w := strings.ToLower(word)
@@ -64,42 +279,112 @@ func createChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request)
 			}
 		}
 
-		chirp, err := cfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-			Body:   strings.Join(words, " "),
-			UserID: userID,
+		var chirp database.Chirp
+		err := cfg.inTx(req.Context(), func(q *database.Queries) error {
+			var err error
+			chirp, err = q.CreateChirp(req.Context(), database.CreateChirpParams{
+				Body:      strings.Join(words, " "),
+				UserID:    userID,
+				ParentID:  parentID,
+				RootID:    rootID,
+				QuoteOfID: quoteOfID,
+			})
+			if err != nil {
+				return err
+			}
+			return cfg.timelines.Posted(req.Context(), q, chirp)
 		})
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create chirp", err)
 			return
 		}
 
-		utils.RespondWithJSON(w, http.StatusCreated, chirpResponse{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+		response, err := cfg.chirpResponses(req.Context(), []database.Chirp{chirp})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusCreated, response[0])
 	}
 }
 
 func getAllChirps(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		chirps, err := cfg.db.GetAllChrips(req.Context())
+		query := req.URL.Query()
+
+		authorID := uuid.NullUUID{}
+		if s := query.Get("author_id"); s != "" {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+				return
+			}
+			authorID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+
+		sort := query.Get("sort")
+		if sort == "" {
+			sort = "asc"
+		}
+		if sort != "asc" && sort != "desc" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Sort must be asc or desc", nil)
+			return
+		}
+
+		limit, err := parseLimit(query.Get("limit"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize), err)
+			return
+		}
+
+		// Fetch one extra row to find out whether there is a next page.
+		params := database.ListChirpsAscParams{
+			AuthorID: authorID,
+			Limit:    int32(limit + 1),
+		}
+
+		scope := fmt.Sprintf("chirps:%s:%s", sort, query.Get("author_id"))
+		if s := query.Get("cursor"); s != "" {
+			cursor, err := pagination.Decode(s, scope, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+				return
+			}
+			params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+			params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		}
+
+		var chirps []database.Chirp
+		if sort == "asc" {
+			chirps, err = cfg.db.ListChirpsAsc(req.Context(), params)
+		} else {
+			chirps, err = cfg.db.ListChirpsDesc(req.Context(), database.ListChirpsDescParams(params))
+		}
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirps", err)
 			return
 		}
 
-		response := make([]chirpResponse, len(chirps))
-		for i, chirp := range chirps {
-			response[i] = chirpResponse{
-				ID:        chirp.ID,
-				CreatedAt: chirp.CreatedAt,
-				UpdatedAt: chirp.UpdatedAt,
-				Body:      chirp.Body,
-				UserID:    chirp.UserID,
+		if len(chirps) > limit {
+			chirps = chirps[:limit]
+			last := chirps[len(chirps)-1]
+			next, err := pagination.Encode(pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.ID,
+				Scope:     scope,
+			}, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make cursor", err)
+				return
 			}
+			setNextLink(w, req, next)
+		}
+
+		response, err := cfg.chirpResponses(req.Context(), chirps)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
 		}
 
 		utils.RespondWithJSON(w, http.StatusOK, response)
@@ -116,22 +401,122 @@ func getChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		chirp, err := cfg.db.GetChrip(req.Context(), chirpUUID)
+		chirp, ok := cfg.findChirp(w, req, chirpUUID)
+		if !ok {
+			return
+		}
+
+		response, err := cfg.chirpResponses(req.Context(), []database.Chirp{chirp})
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				utils.RespondWithError(w, http.StatusNotFound, "Chirp not found", nil)
-			} else {
-				utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
-			}
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, response[0])
+	}
+}
+
+// findChirp looks up a chirp that has not been deleted. It responds and
+// returns false if there is none.
+func (cfg *apiConfig) findChirp(w http.ResponseWriter, req *http.Request, chirpID uuid.UUID) (database.Chirp, bool) {
+	chirp, err := cfg.db.GetChrip(req.Context(), chirpID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+		return database.Chirp{}, false
+	}
+	if err != nil || chirp.DeletedAt.Valid {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// removeChirp deletes a chirp, or leaves a tombstone in its place if it has
+// replies. Quotes of a deleted chirp stay. Tombstones that are left without
+// replies are deleted as well, up the thread.
+//
+// q has to be bound to a transaction. The chirp is locked first so that a
+// reply posted at the same time either counts towards the check for replies
+// or fails because the chirp is gone, rather than being orphaned into a
+// thread of its own.
+func removeChirp(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.LockChirp(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	tombstoned, err := q.TombstoneChirp(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	if tombstoned > 0 {
+		// Reposts have nothing of their own to show once the chirp is
+		// gone. Deleting the chirp outright removes them along with it.
+		return q.DeleteReposts(ctx, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+
+	if err := q.DeleteChirp(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	parentID := chirp.ParentID
+	for parentID.Valid {
+		parentID, err = q.PruneTombstone(ctx, parentID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChirp removes a chirp owned by the authenticated user. Moderators and
+// admins may delete any chirp; doing so is recorded in the audit log.
+func deleteChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID := userIDFromContext(req.Context())
+
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
 			return
 		}
 
-		utils.RespondWithJSON(w, http.StatusOK, chirpResponse{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
+		chirp, ok := cfg.findChirp(w, req, chirpUUID)
+		if !ok {
+			return
+		}
+
+		asModerator := false
+		if chirp.UserID != userID {
+			if !auth.HasRole(roleFromContext(req.Context()), auth.RoleModerator) {
+				utils.RespondWithError(w, http.StatusForbidden, "You can only delete your own chirps", nil)
+				return
+			}
+			asModerator = true
+		}
+
+		// A moderator's deletion only goes ahead if it is recorded.
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			if err := removeChirp(req.Context(), q, chirp); err != nil {
+				return err
+			}
+			if !asModerator {
+				return nil
+			}
+			return q.CreateAuditLogEntry(req.Context(), database.CreateAuditLogEntryParams{
+				ActorID:  uuid.NullUUID{UUID: userID, Valid: true},
+				Action:   "chirp.delete",
+				TargetID: chirp.ID,
+				Details:  fmt.Sprintf("owner=%s body=%q", chirp.UserID, chirp.Body),
+			})
 		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete chirp", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+)
+
+func TestChirpResponsesSharedChirps(t *testing.T) {
+	cfg := newTestConfig(t)
+	prefix := seedPrefix(t, cfg.sqlDB)
+	ctx := context.Background()
+
+	authorID := seedUser(t, cfg.sqlDB, prefix+"author@example.com")
+	quoterID := seedUser(t, cfg.sqlDB, prefix+"quoter@example.com")
+
+	seedChirp := func(userID uuid.UUID, body string, parentID, quoteOfID uuid.NullUUID) database.Chirp {
+		var chirp database.Chirp
+		err := cfg.sqlDB.QueryRow(`
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, quote_of_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id`,
+			body, userID, parentID, quoteOfID).Scan(
+			&chirp.ID,
+			&chirp.CreatedAt,
+			&chirp.UpdatedAt,
+			&chirp.Body,
+			&chirp.UserID,
+			&chirp.ParentID,
+			&chirp.RootID,
+			&chirp.DeletedAt,
+			&chirp.RepostOfID,
+			&chirp.QuoteOfID,
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return chirp
+	}
+
+	original := seedChirp(authorID, "Original", uuid.NullUUID{}, uuid.NullUUID{})
+	seedChirp(quoterID, "Reply", uuid.NullUUID{UUID: original.ID, Valid: true}, uuid.NullUUID{})
+	quote := seedChirp(quoterID, "Quote", uuid.NullUUID{}, uuid.NullUUID{UUID: original.ID, Valid: true})
+
+	t.Run("inlines quoted chirp", func(t *testing.T) {
+		response, err := cfg.chirpResponses(ctx, []database.Chirp{quote})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if response[0].QuoteOf == nil || response[0].QuoteOf.UserID != authorID {
+			t.Errorf("expected quoted chirp by %s, got %+v", authorID, response[0].QuoteOf)
+		}
+	})
+
+	t.Run("leaves out tombstoned quoted chirp", func(t *testing.T) {
+		// The reply keeps the original around as a tombstone.
+		tombstoned, err := cfg.db.TombstoneChirp(ctx, original.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if tombstoned != 1 {
+			t.Fatalf("expected chirp to be tombstoned, got %d rows", tombstoned)
+		}
+
+		response, err := cfg.chirpResponses(ctx, []database.Chirp{quote})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if response[0].QuoteOf != nil {
+			t.Errorf("expected no quoted chirp, got %+v", response[0].QuoteOf)
+		}
+	})
+}
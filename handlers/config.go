@@ -1,19 +1,55 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"sync/atomic"
 
+	"github.com/khizar-sudo/chirpy/internal/auth"
 	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/lockout"
+	"github.com/khizar-sudo/chirpy/internal/mailer"
+	"github.com/khizar-sudo/chirpy/internal/oidc"
+	"github.com/khizar-sudo/chirpy/internal/sessions"
+	"github.com/khizar-sudo/chirpy/internal/timeline"
 	"github.com/khizar-sudo/chirpy/internal/utils"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	sqlDB          *sql.DB
 	platform       string
-	tokenSecret    string
+	tokenKeys      *auth.Keyring
+	sessionCache   *sessions.RevocationCache
+	cursorSecret   []byte
+	polkaKey       string
+	loginTracker   lockout.Tracker
+	passwordPolicy auth.PasswordPolicy
+	mailer         mailer.Mailer
+	baseURL        string
+	oidcProviders  map[string]*oidc.Provider
+	timelines      timeline.Strategy
+	// requireVerifiedEmail stops users from posting chirps until they have
+	// verified their email. They can still log in.
+	requireVerifiedEmail bool
+}
+
+// inTx runs fn with queries bound to a new transaction, committing it if fn
+// returns nil and rolling it back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (cfg *apiConfig) getMetrics(w http.ResponseWriter, req *http.Request) {
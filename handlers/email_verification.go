@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/mailer"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+const (
+	emailVerificationTokenExpiry = 48 * time.Hour
+	// emailVerificationCooldown is how long a user has to wait between
+	// verification emails.
+	emailVerificationCooldown = time.Minute
+)
+
+var errVerificationCooldown = errors.New("verification email sent too recently")
+
+// sendEmailVerification emails user a link to verify their current address.
+// It returns errVerificationCooldown, along with how long is left, if the
+// previous email was sent less than emailVerificationCooldown ago.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, user database.User) (time.Duration, error) {
+	latest, err := cfg.db.GetLatestEmailVerificationToken(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if err == nil {
+		if wait := time.Until(latest.CreatedAt.Add(emailVerificationCooldown)); wait > 0 {
+			return wait, errVerificationCooldown
+		}
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return 0, err
+	}
+
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationTokenExpiry),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Welcome to Chirpy! Open this link within the next two days to verify your email address:\n\n%s/api/users/verify?token=%s",
+			cfg.baseURL, url.QueryEscape(token),
+		),
+	})
+}
+
+func verifyEmail(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token := req.URL.Query().Get("token")
+		if token == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Token is required", nil)
+			return
+		}
+
+		verification, err := cfg.db.UseEmailVerificationToken(req.Context(), auth.HashToken(token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		// The token only verifies the address it was sent to, in case the
+		// user changed their email since.
+		verified, err := cfg.db.VerifyUserEmail(req.Context(), database.VerifyUserEmailParams{
+			ID:    verification.UserID,
+			Email: verification.Email,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not verify email", err)
+			return
+		}
+		if verified == 0 {
+			utils.RespondWithError(w, http.StatusGone, "Email has changed or is already verified", nil)
+			return
+		}
+
+		if err := cfg.db.DeleteUserEmailVerificationTokens(req.Context(), verification.UserID); err != nil {
+			log.Printf("Could not delete verification tokens of user %s: %s", verification.UserID, err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func resendEmailVerification(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := cfg.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		if user.EmailVerifiedAt.Valid {
+			utils.RespondWithError(w, http.StatusConflict, "Email is already verified", nil)
+			return
+		}
+
+		wait, err := cfg.sendEmailVerification(req.Context(), user)
+		if err != nil {
+			if errors.Is(err, errVerificationCooldown) {
+				setRetryAfter(w, wait)
+				utils.RespondWithError(w, http.StatusTooManyRequests, "Verification email sent too recently, try again later", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not send verification email", err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
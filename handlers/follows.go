@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/pagination"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type followResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// followTarget parses the user in the path and checks that it exists and is
+// not the authenticated user. It responds and returns false otherwise.
+func (cfg *apiConfig) followTarget(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, false
+	}
+
+	if followeeID == userIDFromContext(req.Context()) {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot follow yourself", nil)
+		return uuid.Nil, false
+	}
+
+	if _, err := cfg.db.GetUserByID(req.Context(), followeeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		}
+		return uuid.Nil, false
+	}
+
+	return followeeID, true
+}
+
+func followUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		followerID := userIDFromContext(req.Context())
+
+		followeeID, ok := cfg.followTarget(w, req)
+		if !ok {
+			return
+		}
+
+		var created int64
+		err := cfg.inTx(req.Context(), func(q *database.Queries) error {
+			var err error
+			created, err = q.CreateFollow(req.Context(), database.CreateFollowParams{
+				FollowerID: followerID,
+				FolloweeID: followeeID,
+			})
+			if err != nil || created == 0 {
+				return err
+			}
+			return cfg.timelines.Followed(req.Context(), q, followerID, followeeID)
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow user", err)
+			return
+		}
+
+		if created == 0 {
+			utils.RespondWithError(w, http.StatusConflict, "You already follow this user", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func unfollowUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		followerID := userIDFromContext(req.Context())
+
+		followeeID, err := uuid.Parse(req.PathValue("userID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		var deleted int64
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			var err error
+			deleted, err = q.DeleteFollow(req.Context(), database.DeleteFollowParams{
+				FollowerID: followerID,
+				FolloweeID: followeeID,
+			})
+			if err != nil || deleted == 0 {
+				return err
+			}
+			return cfg.timelines.Unfollowed(req.Context(), q, followerID, followeeID)
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not unfollow user", err)
+			return
+		}
+
+		if deleted == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "You do not follow this user", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// followPage fetches a page of follows starting after the cursor, if any.
+type followPage func(ctx context.Context, userID uuid.UUID, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, limit int32) ([]followResponse, error)
+
+func listFollowers(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return cfg.listFollows("followers", func(ctx context.Context, userID uuid.UUID, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, limit int32) ([]followResponse, error) {
+		rows, err := cfg.db.ListFollowers(ctx, database.ListFollowersParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           limit,
+		})
+		follows := make([]followResponse, len(rows))
+		for i, row := range rows {
+			follows[i] = followResponse(row)
+		}
+		return follows, err
+	})
+}
+
+func listFollowing(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return cfg.listFollows("following", func(ctx context.Context, userID uuid.UUID, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, limit int32) ([]followResponse, error) {
+		rows, err := cfg.db.ListFollowing(ctx, database.ListFollowingParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           limit,
+		})
+		follows := make([]followResponse, len(rows))
+		for i, row := range rows {
+			follows[i] = followResponse(row)
+		}
+		return follows, err
+	})
+}
+
+// listFollows pages through one side of a user's follows, newest first.
+func (cfg *apiConfig) listFollows(kind string, fetch followPage) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, err := uuid.Parse(req.PathValue("userID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		query := req.URL.Query()
+
+		limit, err := parseLimit(query.Get("limit"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize), err)
+			return
+		}
+
+		scope := fmt.Sprintf("%s:%s", kind, userID)
+		cursorCreatedAt := sql.NullTime{}
+		cursorID := uuid.NullUUID{}
+		if s := query.Get("cursor"); s != "" {
+			cursor, err := pagination.Decode(s, scope, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+				return
+			}
+			cursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+			cursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		}
+
+		// Fetch one extra row to find out whether there is a next page.
+		follows, err := fetch(req.Context(), userID, cursorCreatedAt, cursorID, int32(limit+1))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch "+kind, err)
+			return
+		}
+
+		if len(follows) > limit {
+			follows = follows[:limit]
+			last := follows[len(follows)-1]
+			next, err := pagination.Encode(pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.UserID,
+				Scope:     scope,
+			}, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make cursor", err)
+				return
+			}
+			setNextLink(w, req, next)
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, follows)
+	}
+}
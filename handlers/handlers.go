@@ -1,14 +1,25 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/joho/godotenv"
+	"github.com/khizar-sudo/chirpy/internal/auth"
 	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/lockout"
+	"github.com/khizar-sudo/chirpy/internal/mailer"
+	"github.com/khizar-sudo/chirpy/internal/sessions"
+	"github.com/khizar-sudo/chirpy/internal/timeline"
 )
 
 func Init() {
@@ -17,9 +28,35 @@ func Init() {
 	if dbURL == "" {
 		log.Fatal("DB_URL must be set")
 	}
+
 	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+
+	tokenKeys, err := loadTokenKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadPasswordParams(); err != nil {
+		log.Fatal(err)
+	}
+
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	polkaKey := os.Getenv("POLKA_KEY")
+	if polkaKey == "" {
+		log.Fatal("POLKA_KEY must be set")
+	}
+
+	cursorSecret := []byte(os.Getenv("CURSOR_SECRET"))
+	if len(cursorSecret) == 0 {
+		log.Println("CURSOR_SECRET not set, using a random key; pagination cursors will not survive restarts")
+		cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cursorSecret); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	db, err := sql.Open("postgres", dbURL)
@@ -27,23 +64,140 @@ func Init() {
 		log.Fatal(err)
 	}
 
+	queries := database.New(db)
+
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		if err := bootstrapAdmin(context.Background(), queries, adminEmail, os.Getenv("ADMIN_PASSWORD")); err != nil {
+			log.Fatalf("Could not bootstrap admin: %s", err)
+		}
+	}
+
+	var loginTracker lockout.Tracker
+	switch os.Getenv("LOGIN_TRACKER") {
+	case "", "postgres":
+		loginTracker = lockout.NewPostgresTracker(lockout.DefaultPolicy, queries)
+	case "memory":
+		loginTracker = lockout.NewMemoryTracker(lockout.DefaultPolicy)
+	default:
+		log.Fatal("LOGIN_TRACKER must be postgres or memory")
+	}
+
+	timelines, err := loadTimelineStrategy(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mail mailer.Mailer
+	switch os.Getenv("MAILER") {
+	case "":
+		// Logging emails instead of sending them is only fine while
+		// developing; anywhere else it has to be asked for.
+		if platform != "dev" {
+			log.Fatal("MAILER must be set")
+		}
+		mail = mailer.LogMailer{}
+	case "log":
+		mail = mailer.LogMailer{}
+	case "file":
+		mailFile := os.Getenv("MAILER_FILE")
+		if mailFile == "" {
+			log.Fatal("MAILER_FILE must be set when MAILER is file")
+		}
+		mail = mailer.NewFileMailer(mailFile)
+	case "smtp":
+		mail, err = mailer.NewSMTPMailer(os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+		if err != nil {
+			log.Fatalf("Could not set up SMTP mailer: %s", err)
+		}
+	default:
+		log.Fatal("MAILER must be log, file or smtp")
+	}
+
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	oidcProviders, err := loadOIDCProviders(baseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	cfg := apiConfig{
 		fileserverHits: atomic.Int32{},
-		db:             database.New(db),
+		db:             queries,
+		sqlDB:          db,
 		platform:       platform,
+		tokenKeys:      tokenKeys,
+		sessionCache:   sessions.NewRevocationCache(sessionCacheTTL, sessionLookup(queries)),
+		cursorSecret:   cursorSecret,
+		polkaKey:       polkaKey,
+		loginTracker:   loginTracker,
+		passwordPolicy: passwordPolicy,
+		mailer:         mail,
+		baseURL:        baseURL,
+		oidcProviders:  oidcProviders,
+		timelines:      timelines,
+
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
 	}
 	mux := http.NewServeMux()
 
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
-	mux.HandleFunc("GET /admin/metrics", cfg.getMetrics)
-	mux.HandleFunc("POST /admin/reset", cfg.resetMetrics)
+	mux.HandleFunc("GET /admin/metrics", cfg.middlewareRequireRole(auth.RoleModerator, cfg.getMetrics))
+	mux.HandleFunc("POST /admin/reset", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.resetMetrics))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", cfg.middlewareRequireRole(auth.RoleModerator, unlockUser(&cfg)))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", cfg.middlewareRequireRole(auth.RoleAdmin, setUserRole(&cfg)))
+
+	mux.HandleFunc("GET /.well-known/jwks.json", jwks(&cfg))
+
+	mux.HandleFunc("GET /oauth/authorize", oauthAuthorize(&cfg))
+	mux.HandleFunc("POST /oauth/authorize", oauthConsent(&cfg))
+	mux.HandleFunc("POST /oauth/token", oauthToken(&cfg))
+	mux.HandleFunc("POST /oauth/introspect", oauthIntrospect(&cfg))
+	mux.HandleFunc("POST /oauth/revoke", oauthRevoke(&cfg))
 
 	mux.HandleFunc("GET /api/healthz", healthCheck)
 	mux.HandleFunc("POST /api/users", createUser(&cfg))
+	mux.HandleFunc("PUT /api/users", cfg.middlewareRequireSession(updateUser(&cfg)))
+	mux.HandleFunc("GET /api/users/{userID}", getUserProfile(&cfg))
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.middlewareRequireScope(auth.ScopeFollowsWrite, followUser(&cfg)))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.middlewareRequireScope(auth.ScopeFollowsWrite, unfollowUser(&cfg)))
+	mux.HandleFunc("GET /api/users/{userID}/followers", listFollowers(&cfg))
+	mux.HandleFunc("GET /api/users/{userID}/following", listFollowing(&cfg))
+	mux.HandleFunc("GET /api/users/verify", verifyEmail(&cfg))
+	mux.HandleFunc("POST /api/users/verify/resend", cfg.middlewareRequireSession(resendEmailVerification(&cfg)))
+	mux.HandleFunc("POST /api/users/me/mfa/totp", cfg.middlewareRequireSession(enrollTOTP(&cfg)))
+	mux.HandleFunc("POST /api/users/me/mfa/totp/confirm", cfg.middlewareRequireSession(confirmTOTP(&cfg)))
+	mux.HandleFunc("DELETE /api/users/me/mfa/totp", cfg.middlewareRequireSession(disableTOTP(&cfg)))
+	mux.HandleFunc("POST /api/users/me/keys", cfg.middlewareRequireSession(createAPIKey(&cfg)))
+	mux.HandleFunc("GET /api/users/me/keys", cfg.middlewareRequireSession(listAPIKeys(&cfg)))
+	mux.HandleFunc("DELETE /api/users/me/keys/{keyID}", cfg.middlewareRequireSession(revokeAPIKey(&cfg)))
+	mux.HandleFunc("POST /api/oauth/clients", cfg.middlewareRequireSession(createOAuthClient(&cfg)))
+	mux.HandleFunc("GET /api/oauth/clients", cfg.middlewareRequireSession(listOAuthClients(&cfg)))
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", cfg.middlewareRequireSession(deleteOAuthClient(&cfg)))
+	mux.HandleFunc("GET /api/sessions", cfg.middlewareRequireSession(listSessions(&cfg)))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.middlewareRequireSession(deleteSession(&cfg)))
 	mux.HandleFunc("POST /api/login", login(&cfg))
-	mux.HandleFunc("POST /api/chirps", createChirp(&cfg))
-	mux.HandleFunc("GET /api/chirps", getAllChirps(&cfg))
-	mux.HandleFunc("GET /api/chirps/{chirpID}", getChirp(&cfg))
+	mux.HandleFunc("POST /api/login/mfa", loginMFA(&cfg))
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/start", oidcStart(&cfg))
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/callback", oidcCallback(&cfg))
+	mux.HandleFunc("POST /api/password-reset/request", requestPasswordReset(&cfg))
+	mux.HandleFunc("POST /api/password-reset/confirm", confirmPasswordReset(&cfg))
+	mux.HandleFunc("POST /api/refresh", refresh(&cfg))
+	mux.HandleFunc("POST /api/revoke", revoke(&cfg))
+	mux.HandleFunc("POST /api/chirps", cfg.middlewareRequireScope(auth.ScopeChirpsWrite, createChirp(&cfg)))
+	mux.HandleFunc("GET /api/timeline", cfg.middlewareRequireScope(auth.ScopeChirpsRead, getTimeline(&cfg)))
+	mux.HandleFunc("GET /api/chirps", cfg.middlewareOptionalScope(auth.ScopeChirpsRead, getAllChirps(&cfg)))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.middlewareOptionalScope(auth.ScopeChirpsRead, getChirp(&cfg)))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.middlewareOptionalScope(auth.ScopeChirpsRead, getChirpThread(&cfg)))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.middlewareRequireScope(auth.ScopeLikesWrite, likeChirp(&cfg)))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.middlewareRequireScope(auth.ScopeLikesWrite, unlikeChirp(&cfg)))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", cfg.middlewareOptionalScope(auth.ScopeChirpsRead, listChirpLikes(&cfg)))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/repost", cfg.middlewareRequireScope(auth.ScopeChirpsWrite, repostChirp(&cfg)))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/repost", cfg.middlewareRequireScope(auth.ScopeChirpsWrite, unrepostChirp(&cfg)))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.middlewareRequireScope(auth.ScopeChirpsWrite, deleteChirp(&cfg)))
+	mux.HandleFunc("POST /api/polka/webhooks", polkaWebhook(&cfg))
 
 	server := http.Server{
 		Handler: mux,
@@ -51,3 +205,143 @@ func Init() {
 	}
 	log.Fatal(server.ListenAndServe())
 }
+
+// loadTokenKeys signs tokens with the asymmetric key in JWT_SIGNING_KEY_FILE
+// when it is set and with the HMAC secret in JWT_SECRET otherwise. Keys that
+// were rotated out go in JWT_PREVIOUS_KEY_FILES or JWT_PREVIOUS_SECRETS so
+// that tokens they signed stay valid until they expire.
+func loadTokenKeys() (*auth.Keyring, error) {
+	if keyFile := os.Getenv("JWT_SIGNING_KEY_FILE"); keyFile != "" {
+		current, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var previous [][]byte
+		for _, f := range splitList(os.Getenv("JWT_PREVIOUS_KEY_FILES")) {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			previous = append(previous, data)
+		}
+
+		keys, err := auth.NewKeyringFromPEM(current, previous...)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE: %w", err)
+		}
+		return keys, nil
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET or JWT_SIGNING_KEY_FILE must be set")
+	}
+
+	keys, err := auth.NewKeyring(secret, splitList(os.Getenv("JWT_PREVIOUS_SECRETS"))...)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_SECRET: %w", err)
+	}
+	return keys, nil
+}
+
+// loadPasswordParams raises the cost of password hashes from ARGON2_MEMORY_KIB,
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM. Existing hashes are upgraded the
+// next time their user logs in.
+func loadPasswordParams() error {
+	params := auth.DefaultPasswordParams
+
+	if v := os.Getenv("ARGON2_MEMORY_KIB"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("ARGON2_MEMORY_KIB: %w", err)
+		}
+		params.Memory = uint32(n)
+	}
+
+	if v := os.Getenv("ARGON2_ITERATIONS"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("ARGON2_ITERATIONS: %w", err)
+		}
+		params.Iterations = uint32(n)
+	}
+
+	if v := os.Getenv("ARGON2_PARALLELISM"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return fmt.Errorf("ARGON2_PARALLELISM: %w", err)
+		}
+		params.Parallelism = uint8(n)
+	}
+
+	return auth.SetPasswordParams(params)
+}
+
+// loadPasswordPolicy reads PASSWORD_MIN_LENGTH and PASSWORD_MIN_ENTROPY on
+// top of the default policy. Passwords listed in BREACHED_PASSWORDS_FILE, a
+// file of SHA-1 hashes, are rejected too.
+func loadPasswordPolicy() (auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return policy, fmt.Errorf("PASSWORD_MIN_LENGTH: %w", err)
+		}
+		policy.MinLength = n
+	}
+
+	if v := os.Getenv("PASSWORD_MIN_ENTROPY"); v != "" {
+		bits, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return policy, fmt.Errorf("PASSWORD_MIN_ENTROPY: %w", err)
+		}
+		policy.MinEntropyBits = bits
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := auth.LoadBreachedList(path)
+		if err != nil {
+			return policy, fmt.Errorf("BREACHED_PASSWORDS_FILE: %w", err)
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// defaultTimelineThreshold is how many accounts a user has to follow before
+// the hybrid strategy materializes their timeline.
+const defaultTimelineThreshold = 500
+
+// loadTimelineStrategy picks how home timelines are built from
+// TIMELINE_STRATEGY: read, write or hybrid, the default. TIMELINE_THRESHOLD
+// sets the hybrid strategy's threshold.
+func loadTimelineStrategy(db *sql.DB) (timeline.Strategy, error) {
+	switch os.Getenv("TIMELINE_STRATEGY") {
+	case "", "hybrid":
+		threshold := int64(defaultTimelineThreshold)
+		if v := os.Getenv("TIMELINE_THRESHOLD"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("TIMELINE_THRESHOLD: %w", err)
+			}
+			threshold = n
+		}
+		return timeline.NewHybrid(db, threshold), nil
+	case "read":
+		return timeline.NewFanOutOnRead(database.New(db)), nil
+	case "write":
+		return timeline.NewFanOutOnWrite(db), nil
+	default:
+		return nil, errors.New("TIMELINE_STRATEGY must be read, write or hybrid")
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
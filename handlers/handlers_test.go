@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/lockout"
+	"github.com/khizar-sudo/chirpy/internal/sessions"
+	_ "github.com/lib/pq"
+)
+
+// The tests that touch the database need a migrated database in
+// TEST_DB_URL. They seed their own users under a unique email prefix and
+// delete them when done.
+
+func openTestDB(tb testing.TB) *sql.DB {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		tb.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		tb.Fatalf("expected no error, got %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestKeyring(tb testing.TB) *auth.Keyring {
+	keys, err := auth.NewKeyring(strings.Repeat("s", auth.MinSecretLength))
+	if err != nil {
+		tb.Fatalf("expected no error, got %v", err)
+	}
+	return keys
+}
+
+// newTestConfig returns an apiConfig backed by the test database.
+func newTestConfig(tb testing.TB) *apiConfig {
+	db := openTestDB(tb)
+	queries := database.New(db)
+	return &apiConfig{
+		db:           queries,
+		sqlDB:        db,
+		tokenKeys:    newTestKeyring(tb),
+		sessionCache: sessions.NewRevocationCache(time.Minute, sessionLookup(queries)),
+		loginTracker: lockout.NewMemoryTracker(lockout.DefaultPolicy),
+	}
+}
+
+// seedPrefix returns a unique email prefix for seeded users and deletes them
+// once the test is done.
+func seedPrefix(tb testing.TB, db *sql.DB) string {
+	prefix := "handlers-test-" + uuid.NewString() + "-"
+	tb.Cleanup(func() {
+		if _, err := db.Exec(`DELETE FROM users WHERE email LIKE $1`, prefix+"%"); err != nil {
+			tb.Errorf("could not delete seeded users: %v", err)
+		}
+	})
+	return prefix
+}
+
+func seedUser(tb testing.TB, db *sql.DB, email string) uuid.UUID {
+	var id uuid.UUID
+	err := db.QueryRow(`
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, 'unset')
+RETURNING id`, email).Scan(&id)
+	if err != nil {
+		tb.Fatalf("expected no error, got %v", err)
+	}
+	return id
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/pagination"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type likeResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// likeChirp likes a chirp for the authenticated user. Liking a repost likes
+// the original. Liking a chirp twice is not an error.
+func likeChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		chirp, ok := cfg.findSharedChirp(w, req, chirpUUID)
+		if !ok {
+			return
+		}
+
+		err = cfg.db.LikeChirp(req.Context(), database.LikeChirpParams{
+			ChirpID: chirp.ID,
+			UserID:  userIDFromContext(req.Context()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not like chirp", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// unlikeChirp removes the authenticated user's like from a chirp, or from
+// the original of a repost, if there is one.
+func unlikeChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		chirpID, err := cfg.originalChirpID(req.Context(), chirpUUID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+			return
+		}
+
+		err = cfg.db.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+			ChirpID: chirpID,
+			UserID:  userIDFromContext(req.Context()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not unlike chirp", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listChirpLikes pages through the users who liked a chirp, or the original
+// of a repost, most recent first.
+func listChirpLikes(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		chirp, ok := cfg.findSharedChirp(w, req, chirpUUID)
+		if !ok {
+			return
+		}
+
+		query := req.URL.Query()
+
+		limit, err := parseLimit(query.Get("limit"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize), err)
+			return
+		}
+
+		// Fetch one extra row to find out whether there is a next page.
+		params := database.ListChirpLikesParams{
+			ChirpID: chirp.ID,
+			Limit:   int32(limit + 1),
+		}
+
+		scope := "likes:" + chirp.ID.String()
+		if s := query.Get("cursor"); s != "" {
+			cursor, err := pagination.Decode(s, scope, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+				return
+			}
+			params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+			params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		}
+
+		likes, err := cfg.db.ListChirpLikes(req.Context(), params)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		if len(likes) > limit {
+			likes = likes[:limit]
+			last := likes[len(likes)-1]
+			next, err := pagination.Encode(pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.UserID,
+				Scope:     scope,
+			}, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make cursor", err)
+				return
+			}
+			setNextLink(w, req, next)
+		}
+
+		response := make([]likeResponse, len(likes))
+		for i, like := range likes {
+			response[i] = likeResponse(like)
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+// loginThrottleKeys returns the lockout keys for a login attempt. The account
+// key comes first; it is the one cleared by a successful login or an admin
+// unlock, while the IP key is left to expire on its own.
+func loginThrottleKeys(email string, req *http.Request) []string {
+	return []string{accountThrottleKey(email), "ip:" + clientIP(req)}
+}
+
+// passwordResetThrottleKeys are counted separately from the login keys so that
+// asking for reset emails cannot lock anyone out of logging in.
+func passwordResetThrottleKeys(email string, req *http.Request) []string {
+	return []string{"password-reset:" + strings.ToLower(email), "password-reset-ip:" + clientIP(req)}
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+// reserveLoginThrottle reserves an attempt for each of keys. It responds with
+// 429 and returns false if any of them has to wait before its next attempt.
+// The attempt counts as failed unless it is released or reset afterwards.
+func (cfg *apiConfig) reserveLoginThrottle(w http.ResponseWriter, req *http.Request, keys []string) bool {
+	wait, err := cfg.reserveLoginAttempt(req.Context(), keys)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		return false
+	}
+
+	if wait > 0 {
+		setRetryAfter(w, wait)
+		utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again later", nil)
+		return false
+	}
+
+	return true
+}
+
+// reserveLoginAttempt reserves an attempt for each of keys and returns how
+// long the longest waiting of them has to wait. If any has to wait, the
+// attempts already reserved for the others are released again.
+func (cfg *apiConfig) reserveLoginAttempt(ctx context.Context, keys []string) (time.Duration, error) {
+	for i, key := range keys {
+		wait, err := cfg.loginTracker.Attempt(ctx, key)
+		if err == nil && wait == 0 {
+			continue
+		}
+		cfg.releaseLoginAttempt(ctx, keys[:i])
+		return wait, err
+	}
+	return 0, nil
+}
+
+// releaseLoginAttempt takes back an attempt reserved for keys, for when it
+// failed for a reason other than wrong credentials.
+func (cfg *apiConfig) releaseLoginAttempt(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := cfg.loginTracker.Release(ctx, key); err != nil {
+			log.Printf("Could not release login attempt for %s: %s", key, err)
+		}
+	}
+}
+
+// loginSucceeded clears the failures of the account key and releases the
+// attempt reserved for the others.
+func (cfg *apiConfig) loginSucceeded(ctx context.Context, keys []string) {
+	if err := cfg.loginTracker.Reset(ctx, keys[0]); err != nil {
+		log.Printf("Could not reset failed logins for %s: %s", keys[0], err)
+	}
+	cfg.releaseLoginAttempt(ctx, keys[1:])
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func unlockUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userUUID, err := uuid.Parse(req.PathValue("userID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(req.Context(), userUUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		if err := cfg.loginTracker.Reset(req.Context(), accountThrottleKey(user.Email)); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not unlock user", err)
+			return
+		}
+
+		err = cfg.db.CreateAuditLogEntry(req.Context(), database.CreateAuditLogEntryParams{
+			ActorID:  uuid.NullUUID{UUID: userIDFromContext(req.Context()), Valid: true},
+			Action:   "user.unlock",
+			TargetID: user.ID,
+			Details:  "",
+		})
+		if err != nil {
+			log.Printf("Could not record unlock of user %s: %s", user.ID, err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
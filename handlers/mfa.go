@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+const (
+	mfaTokenExpiry    = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "Chirpy"
+)
+
+var errTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+type mfaRequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// loginMFA finishes a login for a user with 2FA enabled by exchanging the
+// mfa_token from login and a TOTP or recovery code for real tokens.
+func loginMFA(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := mfaLoginRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if body.MFAToken == "" || body.Code == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "MFA token and code are required", nil)
+			return
+		}
+
+		userID, err := cfg.tokenKeys.ValidateMFAToken(body.MFAToken)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token", err)
+			return
+		}
+
+		throttleKeys := []string{"mfa:" + userID.String()}
+		if !cfg.reserveLoginThrottle(w, req, throttleKeys) {
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(req.Context(), userID)
+		if err != nil {
+			cfg.releaseLoginAttempt(req.Context(), throttleKeys)
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		ok, err := cfg.checkSecondFactor(req.Context(), user, body.Code)
+		if err != nil {
+			cfg.releaseLoginAttempt(req.Context(), throttleKeys)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		if !ok {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Incorrect code", nil)
+			return
+		}
+
+		cfg.loginSucceeded(req.Context(), throttleKeys)
+
+		cfg.respondWithLogin(w, req, user, time.Hour)
+	}
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are used up: a TOTP code is rejected if its time step, or a
+// later one, has already been accepted.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, user database.User, code string) (bool, error) {
+	if !user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+
+	code = strings.ToLower(strings.ReplaceAll(code, " ", ""))
+	if counter, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now()); ok {
+		return cfg.useTOTPCounter(ctx, user.ID, counter)
+	}
+
+	used, err := cfg.db.UseMFARecoveryCode(ctx, database.UseMFARecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: auth.HashToken(code),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+// useTOTPCounter records counter as the last TOTP time step userID has used,
+// reporting false if it was not newer than the one before.
+func (cfg *apiConfig) useTOTPCounter(ctx context.Context, userID uuid.UUID, counter int64) (bool, error) {
+	used, err := cfg.db.UseUserTOTPCounter(ctx, database.UseUserTOTPCounterParams{
+		ID:              userID,
+		TotpLastCounter: sql.NullInt64{Int64: counter, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+// enrollTOTP starts 2FA enrollment. 2FA is only enabled once the user proves
+// their authenticator works through confirmTOTP.
+func enrollTOTP(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := cfg.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		if user.TotpEnabledAt.Valid {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+			return
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not generate secret", err)
+			return
+		}
+
+		err = cfg.db.SetUserTOTPSecret(req.Context(), database.SetUserTOTPSecretParams{
+			ID:         user.ID,
+			TotpSecret: sql.NullString{String: secret, Valid: true},
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not save secret", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, totpEnrollmentResponse{
+			Secret:     secret,
+			OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+		})
+	}
+}
+
+// confirmTOTP enables 2FA once the user sends a valid code for the secret from
+// enrollTOTP, and hands out recovery codes. This is the only time the
+// recovery codes are shown.
+func confirmTOTP(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := mfaCodeRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		user, ok := cfg.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		if user.TotpEnabledAt.Valid {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+			return
+		}
+
+		if !user.TotpSecret.Valid {
+			utils.RespondWithError(w, http.StatusBadRequest, "Start two-factor enrollment first", nil)
+			return
+		}
+
+		counter, ok := auth.ValidateTOTP(user.TotpSecret.String, strings.ReplaceAll(body.Code, " ", ""), time.Now())
+		if !ok {
+			utils.RespondWithError(w, http.StatusBadRequest, "Incorrect code", nil)
+			return
+		}
+
+		ok, err := cfg.useTOTPCounter(req.Context(), user.ID, counter)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		if !ok {
+			utils.RespondWithError(w, http.StatusBadRequest, "Incorrect code", nil)
+			return
+		}
+
+		codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not generate recovery codes", err)
+			return
+		}
+
+		// 2FA is only turned on together with the recovery codes, so a
+		// failure part way cannot leave the user without a way back in.
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			enabled, err := q.EnableUserTOTP(req.Context(), user.ID)
+			if err != nil {
+				return err
+			}
+			if enabled == 0 {
+				return errTOTPAlreadyEnabled
+			}
+
+			if err := q.DeleteUserMFARecoveryCodes(req.Context(), user.ID); err != nil {
+				return err
+			}
+			for _, code := range codes {
+				err := q.CreateMFARecoveryCode(req.Context(), database.CreateMFARecoveryCodeParams{
+					UserID:   user.ID,
+					CodeHash: auth.HashToken(code),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errTOTPAlreadyEnabled) {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+			return
+		}
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not enable two-factor authentication", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, recoveryCodesResponse{
+			RecoveryCodes: codes,
+		})
+	}
+}
+
+// disableTOTP turns 2FA off. It takes a current code so that a stolen access
+// token alone is not enough.
+func disableTOTP(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := mfaCodeRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		user, ok := cfg.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		if !user.TotpEnabledAt.Valid {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled", nil)
+			return
+		}
+
+		ok, err := cfg.checkSecondFactor(req.Context(), user, body.Code)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		if !ok {
+			utils.RespondWithError(w, http.StatusBadRequest, "Incorrect code", nil)
+			return
+		}
+
+		if err := cfg.db.DisableUserTOTP(req.Context(), user.ID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not disable two-factor authentication", err)
+			return
+		}
+
+		if err := cfg.db.DeleteUserMFARecoveryCodes(req.Context(), user.ID); err != nil {
+			log.Printf("Could not delete recovery codes of user %s: %s", user.ID, err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -1,10 +1,272 @@
 package handlers
 
-import "net/http"
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
 
-func(cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type contextKey int
+
+const (
+	userIDContextKey contextKey = iota
+	roleContextKey
+	sessionIDContextKey
+	scopesContextKey
+)
+
+func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
 		next.ServeHTTP(w, r)
 	})
 }
+
+// middlewareAuthenticate rejects requests without a valid access token or
+// personal API key and makes the authenticated user ID available through
+// userIDFromContext. Requests made with an API key are limited to the key's
+// scopes; see middlewareRequireScope.
+func (cfg *apiConfig) middlewareAuthenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, credentials, err := auth.GetAuthorization(r.Header)
+		if err == nil && scheme != auth.SchemeBearer && scheme != auth.SchemeAPIKey {
+			err = auth.ErrUnsupportedAuthScheme
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", auth.SchemeBearer)
+			utils.RespondWithError(w, http.StatusUnauthorized, authHeaderErrorMessage(err), err)
+			return
+		}
+
+		ctx := r.Context()
+		if scheme == auth.SchemeAPIKey || auth.IsAPIKey(credentials) {
+			key, ok := cfg.validateAPIKey(w, r, credentials)
+			if !ok {
+				return
+			}
+			// API keys never carry more than a plain user's privileges.
+			ctx = context.WithValue(ctx, userIDContextKey, key.UserID)
+			ctx = context.WithValue(ctx, roleContextKey, auth.RoleUser)
+			ctx = context.WithValue(ctx, scopesContextKey, key.Scopes)
+		} else {
+			claims, err := cfg.tokenKeys.ParseAccessToken(credentials)
+			var userID uuid.UUID
+			if err == nil {
+				userID, err = uuid.Parse(claims.Subject)
+			}
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", auth.SchemeBearer)
+				utils.RespondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
+				return
+			}
+			ctx = context.WithValue(ctx, userIDContextKey, userID)
+			ctx = context.WithValue(ctx, roleContextKey, claims.Role)
+			if claims.ClientID != "" {
+				ctx = context.WithValue(ctx, scopesContextKey, claims.Scopes())
+			}
+
+			// Tokens issued before sessions were introduced have no sid and
+			// simply run out.
+			if claims.SessionID != "" {
+				sessionID, ok := cfg.checkSession(w, r, claims.SessionID)
+				if !ok {
+					return
+				}
+				ctx = context.WithValue(ctx, sessionIDContextKey, sessionID)
+			}
+		}
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// checkSession rejects access tokens whose session has been logged out. If
+// it does it responds with an error and returns false.
+func (cfg *apiConfig) checkSession(w http.ResponseWriter, r *http.Request, sid string) (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", auth.SchemeBearer)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
+		return uuid.UUID{}, false
+	}
+
+	revoked, err := cfg.sessionCache.Revoked(r.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		return uuid.UUID{}, false
+	}
+
+	if revoked {
+		w.Header().Set("WWW-Authenticate", auth.SchemeBearer)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Session has been logged out", nil)
+		return uuid.UUID{}, false
+	}
+
+	return sessionID, true
+}
+
+// validateAPIKey looks up a personal API key. If the key is unknown, revoked
+// or does not match it responds with an error and returns false.
+func (cfg *apiConfig) validateAPIKey(w http.ResponseWriter, r *http.Request, credentials string) (database.ApiKey, bool) {
+	prefix, ok := auth.ParseAPIKey(credentials)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid API key", nil)
+		return database.ApiKey{}, false
+	}
+
+	key, err := cfg.db.GetAPIKeyByPrefix(r.Context(), prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid API key", nil)
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		}
+		return database.ApiKey{}, false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(credentials)), []byte(key.KeyHash)) != 1 {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid API key", nil)
+		return database.ApiKey{}, false
+	}
+
+	if err := cfg.db.TouchAPIKey(r.Context(), key.ID); err != nil {
+		log.Printf("Could not record use of API key %s: %s", key.ID, err)
+	}
+
+	return key, true
+}
+
+// middlewareRequireScope is middlewareAuthenticate for routes that API keys
+// and third-party apps may only use when they were granted scope. Access
+// tokens from logging in are not limited.
+func (cfg *apiConfig) middlewareRequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareAuthenticate(func(w http.ResponseWriter, r *http.Request) {
+		scopes, limited := scopesFromContext(r.Context())
+		if limited && !slices.Contains(scopes, scope) {
+			utils.RespondWithError(w, http.StatusForbidden, "Missing the "+scope+" scope", nil)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// middlewareOptionalAuthenticate is middlewareAuthenticate for public routes
+// that show more to signed-in users. Requests without an Authorization
+// header go through anonymously; invalid credentials are still rejected.
+func (cfg *apiConfig) middlewareOptionalAuthenticate(next http.HandlerFunc) http.HandlerFunc {
+	authenticated := cfg.middlewareAuthenticate(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+
+		authenticated(w, r)
+	}
+}
+
+// middlewareOptionalScope is middlewareOptionalAuthenticate for public routes
+// that also fall under scope. Anonymous requests go through, but API keys and
+// app tokens need the scope like they would with middlewareRequireScope.
+func (cfg *apiConfig) middlewareOptionalScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareOptionalAuthenticate(func(w http.ResponseWriter, r *http.Request) {
+		scopes, limited := scopesFromContext(r.Context())
+		if limited && !slices.Contains(scopes, scope) {
+			utils.RespondWithError(w, http.StatusForbidden, "Missing the "+scope+" scope", nil)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// middlewareRequireSession is middlewareAuthenticate for routes that must not
+// be reachable with an API key or by a third-party app, such as managing the
+// keys themselves.
+func (cfg *apiConfig) middlewareRequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareAuthenticate(func(w http.ResponseWriter, r *http.Request) {
+		if _, limited := scopesFromContext(r.Context()); limited {
+			utils.RespondWithError(w, http.StatusForbidden, "API keys and app tokens cannot be used for this request", nil)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// middlewareRequireRole is middlewareRequireSession for routes that need at
+// least the given role. The role comes from the access token, so a change of
+// role takes effect once the user's current access token expires.
+func (cfg *apiConfig) middlewareRequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareRequireSession(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.HasRole(roleFromContext(r.Context()), role) {
+			utils.RespondWithError(w, http.StatusForbidden, "Forbidden", nil)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func userIDFromContext(ctx context.Context) uuid.UUID {
+	userID, _ := ctx.Value(userIDContextKey).(uuid.UUID)
+	return userID
+}
+
+func roleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleContextKey).(string)
+	return role
+}
+
+// sessionIDFromContext returns the session of the access token used to
+// authenticate the request, or uuid.Nil for API keys and older tokens.
+func sessionIDFromContext(ctx context.Context) uuid.UUID {
+	sessionID, _ := ctx.Value(sessionIDContextKey).(uuid.UUID)
+	return sessionID
+}
+
+// scopesFromContext returns the scopes of the API key or app token used to
+// authenticate the request. limited is false for requests made with an
+// access token from logging in.
+func scopesFromContext(ctx context.Context) (scopes []string, limited bool) {
+	scopes, limited = ctx.Value(scopesContextKey).([]string)
+	return scopes, limited
+}
+
+// currentUser loads the authenticated user. If that fails it responds with an
+// error and returns false.
+func (cfg *apiConfig) currentUser(w http.ResponseWriter, req *http.Request) (database.User, bool) {
+	user, err := cfg.db.GetUserByID(req.Context(), userIDFromContext(req.Context()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+		}
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func authHeaderErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrNoAuthHeader):
+		return "No token provided"
+	case errors.Is(err, auth.ErrMalformedAuthHeader):
+		return "Malformed authorization header"
+	case errors.Is(err, auth.ErrUnsupportedAuthScheme):
+		return "Unsupported authorization scheme"
+	default:
+		return "Could not validate token"
+	}
+}
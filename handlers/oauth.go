@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/oidc"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+const (
+	oauthCodeExpiry  = 5 * time.Minute
+	oauthTokenExpiry = time.Hour
+)
+
+// scopeDescriptions are shown to users on the consent page.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read chirps",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeFollowsWrite: "Follow and unfollow users as you",
+	auth.ScopeLikesWrite:   "Like and unlike chirps as you",
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Authorize {{.ClientName}} - Chirpy</title></head>
+<body>
+<h1>Authorize {{.ClientName}}</h1>
+<p>{{.ClientName}} would like to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Email <input type="email" name="email" value="{{.Email}}" required></label></p>
+<p><label>Password <input type="password" name="password" required></label></p>
+<p><label>Two-factor code, if enabled <input type="text" name="code" autocomplete="one-time-code"></label></p>
+<p>
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</p>
+</form>
+</body>
+</html>
+`))
+
+type consentPage struct {
+	ClientName string
+	Scopes     []string
+	Params     map[string]string
+	Email      string
+	Error      string
+}
+
+// authorizeRequest is a validated request to /oauth/authorize.
+type authorizeRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	state         string
+	scopes        []string
+	codeChallenge string
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// respondWithOAuthError responds in the format of RFC 6749 section 5.2.
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthError, description string, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, code, oauthErrorResponse{
+		Error:            oauthError,
+		ErrorDescription: description,
+	})
+}
+
+// redirectWithOAuthError sends an authorization error back to the app.
+func redirectWithOAuthError(w http.ResponseWriter, req *http.Request, redirectURI, state, oauthError string) {
+	u, _ := url.Parse(redirectURI)
+	q := u.Query()
+	q.Set("error", oauthError)
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, req, u.String(), http.StatusFound)
+}
+
+// parseAuthorizeRequest validates the parameters of an authorization request.
+// Until the client and redirect URI are known to be good errors are shown to
+// the user; after that they are sent back to the app. In both cases it
+// responds and returns false.
+func (cfg *apiConfig) parseAuthorizeRequest(w http.ResponseWriter, req *http.Request, params url.Values) (authorizeRequest, bool) {
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		http.Error(w, "Invalid client_id", http.StatusBadRequest)
+		return authorizeRequest{}, false
+	}
+
+	client, err := cfg.db.GetOAuthClient(req.Context(), clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Unknown client", http.StatusBadRequest)
+		} else {
+			log.Println(err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+		return authorizeRequest{}, false
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		http.Error(w, "Redirect URI is not registered for this client", http.StatusBadRequest)
+		return authorizeRequest{}, false
+	}
+
+	state := params.Get("state")
+
+	if params.Get("response_type") != "code" {
+		redirectWithOAuthError(w, req, redirectURI, state, "unsupported_response_type")
+		return authorizeRequest{}, false
+	}
+
+	// PKCE is required of every client, confidential or not.
+	codeChallenge := params.Get("code_challenge")
+	if codeChallenge == "" || params.Get("code_challenge_method") != "S256" {
+		redirectWithOAuthError(w, req, redirectURI, state, "invalid_request")
+		return authorizeRequest{}, false
+	}
+
+	scopes := strings.Fields(params.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		// Clients registered before a scope was withdrawn may still list
+		// it, so the client's own scopes are checked as well.
+		if !auth.ValidScope(scope) || !slices.Contains(client.Scopes, scope) {
+			redirectWithOAuthError(w, req, redirectURI, state, "invalid_scope")
+			return authorizeRequest{}, false
+		}
+	}
+
+	return authorizeRequest{
+		client:        client,
+		redirectURI:   redirectURI,
+		state:         state,
+		scopes:        scopes,
+		codeChallenge: codeChallenge,
+	}, true
+}
+
+func renderConsent(w http.ResponseWriter, code int, authReq authorizeRequest, email, message string) {
+	page := consentPage{
+		ClientName: authReq.client.Name,
+		Params: map[string]string{
+			"response_type":         "code",
+			"client_id":             authReq.client.ID.String(),
+			"redirect_uri":          authReq.redirectURI,
+			"scope":                 strings.Join(authReq.scopes, " "),
+			"state":                 authReq.state,
+			"code_challenge":        authReq.codeChallenge,
+			"code_challenge_method": "S256",
+		},
+		Email: email,
+		Error: message,
+	}
+	for _, scope := range authReq.scopes {
+		page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+	}
+
+	// The page takes a password, so it must not be framed by another site.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := consentTemplate.Execute(w, page); err != nil {
+		log.Printf("Could not render consent page: %s", err)
+	}
+}
+
+// oauthAuthorize shows the consent page for an app asking for access.
+func oauthAuthorize(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		authReq, ok := cfg.parseAuthorizeRequest(w, req, req.URL.Query())
+		if !ok {
+			return
+		}
+
+		renderConsent(w, http.StatusOK, authReq, "", "")
+	}
+}
+
+// oauthConsent handles the consent form. The user signs in on the form
+// itself, so the app never sees their password.
+func oauthConsent(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, "Invalid form", http.StatusBadRequest)
+			return
+		}
+
+		authReq, ok := cfg.parseAuthorizeRequest(w, req, req.PostForm)
+		if !ok {
+			return
+		}
+
+		if req.PostForm.Get("decision") != "approve" {
+			redirectWithOAuthError(w, req, authReq.redirectURI, authReq.state, "access_denied")
+			return
+		}
+
+		email := req.PostForm.Get("email")
+		user, message, err := cfg.authenticateConsent(req, email, req.PostForm.Get("password"), req.PostForm.Get("code"))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			renderConsent(w, http.StatusUnauthorized, authReq, email, message)
+			return
+		}
+
+		code, err := auth.MakeRefreshToken()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		err = cfg.db.CreateOAuthAuthorizationCode(req.Context(), database.CreateOAuthAuthorizationCodeParams{
+			CodeHash:      auth.HashToken(code),
+			ClientID:      authReq.client.ID,
+			UserID:        user.ID,
+			RedirectUri:   authReq.redirectURI,
+			Scopes:        authReq.scopes,
+			CodeChallenge: authReq.codeChallenge,
+			ExpiresAt:     time.Now().UTC().Add(oauthCodeExpiry),
+		})
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		u, _ := url.Parse(authReq.redirectURI)
+		q := u.Query()
+		q.Set("code", code)
+		if authReq.state != "" {
+			q.Set("state", authReq.state)
+		}
+		u.RawQuery = q.Encode()
+		http.Redirect(w, req, u.String(), http.StatusFound)
+	}
+}
+
+// authenticateConsent checks the credentials entered on the consent page
+// with the same throttling as login. If they are wrong it returns a message
+// to show on the page.
+func (cfg *apiConfig) authenticateConsent(req *http.Request, email, password, code string) (database.User, string, error) {
+	if email == "" || password == "" {
+		return database.User{}, "Enter your email and password", nil
+	}
+
+	throttleKeys := loginThrottleKeys(email, req)
+	wait, err := cfg.reserveLoginAttempt(req.Context(), throttleKeys)
+	if err != nil {
+		return database.User{}, "", err
+	}
+	if wait > 0 {
+		return database.User{}, "Too many failed attempts, try again later", nil
+	}
+
+	user, err := cfg.db.GetUser(req.Context(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, "Incorrect email or password", nil
+	}
+	if err != nil {
+		cfg.releaseLoginAttempt(req.Context(), throttleKeys)
+		return database.User{}, "", err
+	}
+
+	ok, err := auth.CheckPasswordHash(password, user.HashedPassword)
+	if err != nil && !errors.Is(err, auth.ErrPasswordUnset) {
+		cfg.releaseLoginAttempt(req.Context(), throttleKeys)
+		return database.User{}, "", err
+	}
+	if !ok {
+		return database.User{}, "Incorrect email or password", nil
+	}
+
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.checkSecondFactor(req.Context(), user, code)
+		if err != nil {
+			cfg.releaseLoginAttempt(req.Context(), throttleKeys)
+			return database.User{}, "", err
+		}
+		if !ok {
+			return database.User{}, "Enter a valid two-factor code", nil
+		}
+	}
+
+	cfg.loginSucceeded(req.Context(), throttleKeys)
+
+	return user, "", nil
+}
+
+// authenticateClient identifies the app calling the token, introspection or
+// revocation endpoint. Confidential apps must send their secret, with HTTP
+// Basic authentication or in the form; public apps only send client_id.
+func (cfg *apiConfig) authenticateClient(ctx context.Context, req *http.Request) (database.OauthClient, bool, error) {
+	id, secret, basic := req.BasicAuth()
+	if basic {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = req.PostForm.Get("client_id")
+		secret = req.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, false, nil
+	}
+
+	client, err := cfg.db.GetOAuthClient(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, false, nil
+	}
+	if err != nil {
+		return database.OauthClient{}, false, err
+	}
+
+	if client.SecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, false, nil
+		}
+	}
+
+	return client, true, nil
+}
+
+// oauthToken exchanges an authorization code for an access token. Every
+// authorization starts a session, which the user can end from their list of
+// sessions like any other.
+func oauthToken(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form", err)
+			return
+		}
+
+		client, ok, err := cfg.authenticateClient(req.Context(), req)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+		if !ok {
+			respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "", nil)
+			return
+		}
+
+		if req.PostForm.Get("grant_type") != "authorization_code" {
+			respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "", nil)
+			return
+		}
+
+		code, err := cfg.db.UseOAuthAuthorizationCode(req.Context(), auth.HashToken(req.PostForm.Get("code")))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired code", nil)
+			} else {
+				respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			}
+			return
+		}
+
+		if code.ClientID != client.ID || code.RedirectUri != req.PostForm.Get("redirect_uri") {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Code was issued to another client or redirect URI", nil)
+			return
+		}
+
+		challenge := oidc.CodeChallenge(req.PostForm.Get("code_verifier"))
+		if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Code verifier does not match", nil)
+			return
+		}
+
+		session, err := cfg.db.CreateSession(req.Context(), database.CreateSessionParams{
+			UserID:    code.UserID,
+			UserAgent: client.Name,
+			Ip:        clientIP(req),
+			ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+		})
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+
+		token, err := cfg.tokenKeys.MakeClientJWT(code.UserID, session.ID, client.ID.String(), code.Scopes, oauthTokenExpiry)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		utils.RespondWithJSON(w, http.StatusOK, oauthTokenResponse{
+			AccessToken: token,
+			TokenType:   auth.SchemeBearer,
+			ExpiresIn:   int(oauthTokenExpiry.Seconds()),
+			Scope:       strings.Join(code.Scopes, " "),
+		})
+	}
+}
+
+// clientToken parses an access token presented by the client it was issued
+// to. It returns nil if the token is not valid, not an app token, belongs to
+// another client or its session has ended.
+func (cfg *apiConfig) clientToken(ctx context.Context, client database.OauthClient, token string) (*auth.Claims, error) {
+	claims, err := cfg.tokenKeys.ParseAccessToken(token)
+	if err != nil || claims.ClientID != client.ID.String() {
+		return nil, nil
+	}
+
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, nil
+	}
+
+	revoked, err := cfg.sessionCache.Revoked(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, nil
+	}
+
+	return claims, nil
+}
+
+// oauthIntrospect tells an app whether one of its access tokens is still
+// active, as described in RFC 7662. Apps cannot introspect the tokens of
+// other apps.
+func oauthIntrospect(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form", err)
+			return
+		}
+
+		client, ok, err := cfg.authenticateClient(req.Context(), req)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+		if !ok {
+			respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "", nil)
+			return
+		}
+
+		claims, err := cfg.clientToken(req.Context(), client, req.PostForm.Get("token"))
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if claims == nil {
+			utils.RespondWithJSON(w, http.StatusOK, introspectionResponse{Active: false})
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, introspectionResponse{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Subject:   claims.Subject,
+			TokenType: auth.SchemeBearer,
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			IssuedAt:  claims.IssuedAt.Unix(),
+		})
+	}
+}
+
+// oauthRevoke lets an app give up an access token, ending its session, as
+// described in RFC 7009. Unknown tokens are not an error.
+func oauthRevoke(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form", err)
+			return
+		}
+
+		client, ok, err := cfg.authenticateClient(req.Context(), req)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+		if !ok {
+			respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "", nil)
+			return
+		}
+
+		claims, err := cfg.clientToken(req.Context(), client, req.PostForm.Get("token"))
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+			return
+		}
+
+		if claims != nil {
+			userID, err := uuid.Parse(claims.Subject)
+			if err == nil {
+				_, err = cfg.endSession(req.Context(), userID, uuid.MustParse(claims.SessionID))
+			}
+			if err != nil {
+				respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type oauthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	// Confidential clients get a secret. Apps that cannot keep one, such as
+	// mobile and browser apps, rely on PKCE alone.
+	Confidential bool `json:"confidential"`
+}
+
+type oauthClientResponse struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	Secret       string    `json:"client_secret,omitempty"`
+}
+
+func newOAuthClientResponse(client database.OauthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+	}
+}
+
+// validRedirectURI accepts absolute https URLs, and http URLs on the loopback
+// interface for apps under development.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// createOAuthClient registers a third-party app. The client secret of
+// confidential apps is only ever shown in this response.
+func createOAuthClient(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := oauthClientRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Name is required", nil)
+			return
+		}
+
+		if len(body.RedirectURIs) == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "At least one redirect URI is required", nil)
+			return
+		}
+		for _, uri := range body.RedirectURIs {
+			if !validRedirectURI(uri) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid redirect URI: "+uri, nil)
+				return
+			}
+		}
+
+		if len(body.Scopes) == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+			return
+		}
+		for _, scope := range body.Scopes {
+			if !auth.ValidScope(scope) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Unknown scope: "+scope, nil)
+				return
+			}
+		}
+
+		var secret string
+		var secretHash sql.NullString
+		if body.Confidential {
+			var err error
+			secret, err = auth.MakeRefreshToken()
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make client secret", err)
+				return
+			}
+			secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+		}
+
+		client, err := cfg.db.CreateOAuthClient(req.Context(), database.CreateOAuthClientParams{
+			OwnerID:      userIDFromContext(req.Context()),
+			Name:         body.Name,
+			SecretHash:   secretHash,
+			RedirectUris: body.RedirectURIs,
+			Scopes:       body.Scopes,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not save client", err)
+			return
+		}
+
+		res := newOAuthClientResponse(client)
+		res.Secret = secret
+		utils.RespondWithJSON(w, http.StatusCreated, res)
+	}
+}
+
+func listOAuthClients(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		clients, err := cfg.db.ListUserOAuthClients(req.Context(), userIDFromContext(req.Context()))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not list clients", err)
+			return
+		}
+
+		res := make([]oauthClientResponse, 0, len(clients))
+		for _, client := range clients {
+			res = append(res, newOAuthClientResponse(client))
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, res)
+	}
+}
+
+// deleteOAuthClient removes an app. Every session users gave it ends with
+// it.
+func deleteOAuthClient(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		clientID, err := uuid.Parse(req.PathValue("clientID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+			return
+		}
+
+		// The sessions are revoked first so that their access tokens stop
+		// working right away rather than when the cache forgets them.
+		revoked, err := cfg.db.RevokeClientSessions(req.Context(), database.RevokeClientSessionsParams{
+			ID:      clientID,
+			OwnerID: userIDFromContext(req.Context()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions", err)
+			return
+		}
+		for _, id := range revoked {
+			cfg.sessionCache.MarkRevoked(id)
+		}
+
+		deleted, err := cfg.db.DeleteOAuthClient(req.Context(), database.DeleteOAuthClientParams{
+			ID:      clientID,
+			OwnerID: userIDFromContext(req.Context()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete client", err)
+			return
+		}
+
+		if deleted == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "Client not found", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
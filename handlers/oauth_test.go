@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/oidc"
+)
+
+func TestOAuthToken(t *testing.T) {
+	cfg := newTestConfig(t)
+	prefix := seedPrefix(t, cfg.sqlDB)
+	ctx := context.Background()
+
+	ownerID := seedUser(t, cfg.sqlDB, prefix+"owner@example.com")
+	userID := seedUser(t, cfg.sqlDB, prefix+"user@example.com")
+
+	const redirectURI = "https://app.example.com/callback"
+	const verifier = "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+	client, err := cfg.db.CreateOAuthClient(ctx, database.CreateOAuthClientParams{
+		OwnerID:      ownerID,
+		Name:         "Test app",
+		RedirectUris: []string{redirectURI},
+		Scopes:       []string{auth.ScopeChirpsRead, auth.ScopeChirpsWrite},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// newCode issues an authorization code like the consent page does.
+	newCode := func() string {
+		t.Helper()
+		code, err := auth.MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		err = cfg.db.CreateOAuthAuthorizationCode(ctx, database.CreateOAuthAuthorizationCodeParams{
+			CodeHash:      auth.HashToken(code),
+			ClientID:      client.ID,
+			UserID:        userID,
+			RedirectUri:   redirectURI,
+			Scopes:        []string{auth.ScopeChirpsRead},
+			CodeChallenge: oidc.CodeChallenge(verifier),
+			ExpiresAt:     time.Now().UTC().Add(oauthCodeExpiry),
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return code
+	}
+
+	exchange := func(code, redirect, codeVerifier string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ID.String()},
+			"code":          {code},
+			"redirect_uri":  {redirect},
+			"code_verifier": {codeVerifier},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		oauthToken(cfg)(rec, req)
+		return rec
+	}
+
+	t.Run("exchanges a code for a scoped token", func(t *testing.T) {
+		rec := exchange(newCode(), redirectURI, verifier)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+		}
+
+		var res oauthTokenResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		claims, err := cfg.tokenKeys.ParseAccessToken(res.AccessToken)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if claims.Subject != userID.String() || claims.ClientID != client.ID.String() {
+			t.Errorf("expected token for user %s and client %s, got %s and %s", userID, client.ID, claims.Subject, claims.ClientID)
+		}
+		if !slices.Equal(claims.Scopes(), []string{auth.ScopeChirpsRead}) {
+			t.Errorf("expected scopes %v, got %v", []string{auth.ScopeChirpsRead}, claims.Scopes())
+		}
+		if claims.Role != auth.RoleUser {
+			t.Errorf("expected role %q, got %q", auth.RoleUser, claims.Role)
+		}
+	})
+
+	t.Run("rejects a used code", func(t *testing.T) {
+		code := newCode()
+		if rec := exchange(code, redirectURI, verifier); rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+		}
+
+		if rec := exchange(code, redirectURI, verifier); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("rejects a wrong code verifier", func(t *testing.T) {
+		if rec := exchange(newCode(), redirectURI, "wrong-verifier"); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("rejects another redirect URI", func(t *testing.T) {
+		if rec := exchange(newCode(), "https://evil.example.com/callback", verifier); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("rejects unknown clients", func(t *testing.T) {
+		form := url.Values{"grant_type": {"authorization_code"}, "client_id": {"not-a-client"}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		oauthToken(cfg)(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", rec.Code)
+		}
+	})
+}
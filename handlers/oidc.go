@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/oidc"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+const (
+	oidcStateExpiry = 10 * time.Minute
+	// oidcStateCookie holds the state of the sign-in the browser started, so
+	// that a callback carrying someone else's state is refused.
+	oidcStateCookie = "chirpy_oidc_state"
+)
+
+var (
+	errIdentityNoEmail    = errors.New("identity provider did not share an email address")
+	errIdentityEmailTaken = errors.New("email belongs to another account")
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// loadOIDCProviders reads the identity providers named in OIDC_PROVIDERS.
+// Each provider NAME is configured with OIDC_NAME_ISSUER,
+// OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET and optionally
+// OIDC_NAME_SCOPES, which defaults to "email".
+func loadOIDCProviders(baseURL string) (map[string]*oidc.Provider, error) {
+	providers := map[string]*oidc.Provider{}
+
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS")) {
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("OIDC_PROVIDERS: invalid provider name %q", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		issuer := os.Getenv(prefix + "ISSUER")
+		clientID := os.Getenv(prefix + "CLIENT_ID")
+		if issuer == "" || clientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+
+		scopes := strings.Fields(os.Getenv(prefix + "SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"email"}
+		}
+
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Name:         name,
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/api/auth/oidc/%s/callback", baseURL, name),
+			Scopes:       scopes,
+		})
+	}
+
+	return providers, nil
+}
+
+// oidcStart sends the user to the identity provider to sign in.
+func oidcStart(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		provider, ok := cfg.oidcProviders[req.PathValue("provider")]
+		if !ok {
+			utils.RespondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+			return
+		}
+
+		state, err := oidc.RandomString()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		nonce, err := oidc.RandomString()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		verifier, err := oidc.RandomString()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(req.Context(), state, nonce, verifier)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadGateway, "Identity provider is unavailable", err)
+			return
+		}
+
+		if err := cfg.db.DeleteExpiredOIDCLoginStates(req.Context()); err != nil {
+			log.Printf("Could not delete expired OIDC login states: %s", err)
+		}
+
+		err = cfg.db.CreateOIDCLoginState(req.Context(), database.CreateOIDCLoginStateParams{
+			StateHash:    auth.HashToken(state),
+			Provider:     provider.Name(),
+			Nonce:        nonce,
+			CodeVerifier: verifier,
+			ExpiresAt:    time.Now().UTC().Add(oidcStateExpiry),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not save login state", err)
+			return
+		}
+
+		http.SetCookie(w, cfg.oidcStateCookie(state, int(oidcStateExpiry.Seconds())))
+		http.Redirect(w, req, authURL, http.StatusFound)
+	}
+}
+
+// oidcStateCookie binds a sign-in to the browser that started it. A maxAge
+// below zero deletes the cookie.
+func (cfg *apiConfig) oidcStateCookie(state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.baseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// oidcCallback finishes a sign-in with an identity provider. The identity
+// is linked to the user with the same email if both sides have verified it,
+// or to a new user without a password if there is none.
+func oidcCallback(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		provider, ok := cfg.oidcProviders[req.PathValue("provider")]
+		if !ok {
+			utils.RespondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+			return
+		}
+
+		query := req.URL.Query()
+		if query.Get("error") != "" {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Sign-in with the identity provider failed", errors.New(query.Get("error")))
+			return
+		}
+
+		code, state := query.Get("code"), query.Get("state")
+		if code == "" || state == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Code and state are required", nil)
+			return
+		}
+
+		cookie, err := req.Cookie(oidcStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired state", nil)
+			return
+		}
+		http.SetCookie(w, cfg.oidcStateCookie("", -1))
+
+		loginState, err := cfg.db.UseOIDCLoginState(req.Context(), database.UseOIDCLoginStateParams{
+			StateHash: auth.HashToken(state),
+			Provider:  provider.Name(),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired state", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		claims, err := provider.Exchange(req.Context(), code, loginState.CodeVerifier, loginState.Nonce)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Could not verify sign-in with the identity provider", err)
+			return
+		}
+
+		user, err := cfg.userForIdentity(req.Context(), provider.Name(), claims)
+		if err != nil {
+			switch {
+			case errors.Is(err, errIdentityNoEmail):
+				utils.RespondWithError(w, http.StatusBadRequest, "The identity provider did not share your email address", nil)
+			case errors.Is(err, errIdentityEmailTaken):
+				utils.RespondWithError(w, http.StatusConflict, "An account with this email already exists", nil)
+			default:
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		if user.TotpEnabledAt.Valid {
+			mfaToken, err := cfg.tokenKeys.MakeMFAToken(user.ID, mfaTokenExpiry)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make token", err)
+				return
+			}
+
+			utils.RespondWithJSON(w, http.StatusOK, mfaRequiredResponse{
+				MFARequired: true,
+				MFAToken:    mfaToken,
+			})
+			return
+		}
+
+		cfg.respondWithLogin(w, req, user, time.Hour)
+	}
+}
+
+// userForIdentity finds or creates the user an external identity belongs
+// to. An existing account is only linked when the provider has verified
+// that the email is theirs and the account owner has verified it too;
+// otherwise whoever registered the address first could have planted a
+// password on an account the identity's owner would then sign in to.
+func (cfg *apiConfig) userForIdentity(ctx context.Context, provider string, claims *oidc.Claims) (database.User, error) {
+	identity, err := cfg.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		return cfg.db.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" {
+		return database.User{}, errIdentityNoEmail
+	}
+
+	user, err := cfg.db.GetUserByEmailFold(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if !claims.EmailVerified || !user.EmailVerifiedAt.Valid {
+			return database.User{}, errIdentityEmailTaken
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = cfg.db.CreateUser(ctx, database.CreateUserParams{
+			Email:          claims.Email,
+			HashedPassword: auth.UnsetPasswordHash,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	default:
+		return database.User{}, err
+	}
+
+	if claims.EmailVerified && !user.EmailVerifiedAt.Valid {
+		if _, err := cfg.db.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+			ID:    user.ID,
+			Email: user.Email,
+		}); err != nil {
+			return database.User{}, err
+		}
+		if user, err = cfg.db.GetUserByID(ctx, user.ID); err != nil {
+			return database.User{}, err
+		}
+	}
+
+	_, err = cfg.db.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
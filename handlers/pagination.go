@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func parseLimit(s string) (int, error) {
+	if s == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 || limit > maxPageSize {
+		return 0, errors.New("limit out of range")
+	}
+
+	return limit, nil
+}
+
+// setNextLink points the client at the next page through a Link header so
+// that list endpoints can keep returning plain JSON arrays.
+func setNextLink(w http.ResponseWriter, req *http.Request, cursor string) {
+	next := *req.URL
+	query := next.Query()
+	query.Set("cursor", cursor)
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
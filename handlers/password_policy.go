@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type passwordPolicyResponse struct {
+	Error      string                 `json:"error"`
+	Violations []auth.PolicyViolation `json:"violations"`
+}
+
+// checkPasswordPolicy responds with 422 and every failed rule if password
+// does not meet the password policy, and returns false.
+func (cfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password, email string) bool {
+	violations, err := cfg.passwordPolicy.Check(password, email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not check password", err)
+		return false
+	}
+
+	if len(violations) > 0 {
+		utils.RespondWithJSON(w, http.StatusUnprocessableEntity, passwordPolicyResponse{
+			Error:      "Password does not meet the password policy",
+			Violations: violations,
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/mailer"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+const passwordResetTokenExpiry = time.Hour
+
+type passwordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// requestPasswordReset always responds with 202 so that it cannot be used to
+// find out whether an account exists.
+func requestPasswordReset(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := passwordResetRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if body.Email == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Email is required", nil)
+			return
+		}
+
+		// Every request counts as a failure, so flooding an inbox or
+		// hammering the endpoint is slowed down like guessing passwords.
+		throttleKeys := passwordResetThrottleKeys(body.Email, req)
+		if !cfg.reserveLoginThrottle(w, req, throttleKeys) {
+			return
+		}
+
+		if err := cfg.sendPasswordReset(req.Context(), body.Email); err != nil {
+			log.Printf("Could not start password reset: %s", err)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUser(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetTokenExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your Chirpy account. If it was you, send this token with your new password to %s/api/password-reset/confirm within the next hour:\n\n%s\n\nIf it wasn't you, you can ignore this email.",
+			cfg.baseURL, token,
+		),
+	}
+
+	// Send in the background so the response time does not reveal whether
+	// the account exists.
+	go func() {
+		if err := cfg.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("Could not send password reset email to user %s: %s", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// confirmPasswordReset sets a new password using a token from
+// requestPasswordReset. Every session and API key of the user is revoked
+// afterwards.
+func confirmPasswordReset(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body := passwordResetConfirmRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if body.Token == "" || body.Password == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Token and password are required", nil)
+			return
+		}
+
+		// Check the new password before using up the token so that the user
+		// can try again with a better one.
+		resetToken, err := cfg.db.GetPasswordResetToken(req.Context(), auth.HashToken(body.Token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(req.Context(), resetToken.UserID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		if !cfg.checkPasswordPolicy(w, body.Password, user.Email) {
+			return
+		}
+
+		hashPassword, err := auth.HashPassword(body.Password)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		// Using up the token, changing the password and logging the user out
+		// everywhere happen together or not at all.
+		var revoked []uuid.UUID
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			if _, err := q.UsePasswordResetToken(req.Context(), resetToken.TokenHash); err != nil {
+				return err
+			}
+
+			err := q.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+				ID:             resetToken.UserID,
+				HashedPassword: hashPassword,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := q.InvalidateUserPasswordResetTokens(req.Context(), resetToken.UserID); err != nil {
+				return err
+			}
+
+			revoked, err = revokeUserSessions(req.Context(), q, resetToken.UserID)
+			if err != nil {
+				return err
+			}
+
+			return q.RevokeUserAPIKeys(req.Context(), resetToken.UserID)
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not update password", err)
+			}
+			return
+		}
+
+		for _, id := range revoked {
+			cfg.sessionCache.MarkRevoked(id)
+		}
+
+		if err := cfg.loginTracker.Reset(req.Context(), accountThrottleKey(user.Email)); err != nil {
+			log.Printf("Could not reset failed logins for %s: %s", resetToken.UserID, err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+// repostChirp shares a chirp with the authenticated user's followers. The
+// repost is a chirp of its own with an empty body that shows up in listings
+// and timelines with the original inlined. Reposting a repost shares the
+// original.
+func repostChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID := userIDFromContext(req.Context())
+
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		if !cfg.checkCanPost(w, req, userID) {
+			return
+		}
+
+		original, ok := cfg.findSharedChirp(w, req, chirpUUID)
+		if !ok {
+			return
+		}
+
+		var repost database.Chirp
+		err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+			var err error
+			repost, err = q.CreateRepost(req.Context(), database.CreateRepostParams{
+				UserID:     userID,
+				RepostOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			return cfg.timelines.Posted(req.Context(), q, repost)
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusConflict, "You already reposted this chirp", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not repost chirp", err)
+			}
+			return
+		}
+
+		response, err := cfg.chirpResponses(req.Context(), []database.Chirp{repost})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusCreated, response[0])
+	}
+}
+
+// unrepostChirp removes the authenticated user's repost of a chirp. Like
+// repostChirp it accepts a repost in place of the original.
+func unrepostChirp(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		originalID, err := cfg.originalChirpID(req.Context(), chirpUUID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+			return
+		}
+
+		deleted, err := cfg.db.DeleteRepost(req.Context(), database.DeleteRepostParams{
+			UserID:     userIDFromContext(req.Context()),
+			RepostOfID: uuid.NullUUID{UUID: originalID, Valid: true},
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete repost", err)
+			return
+		}
+
+		if deleted == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "You have not reposted this chirp", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
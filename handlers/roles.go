@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// setUserRole lets an admin change the role of another user. Demoted users
+// are logged out everywhere.
+func setUserRole(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userUUID, err := uuid.Parse(req.PathValue("userID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		body := roleRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if !auth.ValidRole(body.Role) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Role must be user, moderator or admin", nil)
+			return
+		}
+
+		actorID := userIDFromContext(req.Context())
+		if userUUID == actorID {
+			utils.RespondWithError(w, http.StatusBadRequest, "You cannot change your own role", nil)
+			return
+		}
+
+		previous, err := cfg.db.GetUserByID(req.Context(), userUUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			}
+			return
+		}
+
+		user, err := cfg.db.SetUserRole(req.Context(), database.SetUserRoleParams{
+			ID:   userUUID,
+			Role: body.Role,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not change role", err)
+			return
+		}
+
+		err = cfg.db.CreateAuditLogEntry(req.Context(), database.CreateAuditLogEntryParams{
+			ActorID:  uuid.NullUUID{UUID: actorID, Valid: true},
+			Action:   "user.role",
+			TargetID: user.ID,
+			Details:  fmt.Sprintf("from=%s to=%s", previous.Role, user.Role),
+		})
+		if err != nil {
+			log.Printf("Could not record role change of user %s: %s", user.ID, err)
+		}
+
+		// Access tokens carry the role, so a demoted user is logged out
+		// rather than keeping their old powers until the tokens expire.
+		if !auth.HasRole(user.Role, previous.Role) {
+			if err := cfg.endUserSessions(req.Context(), user.ID); err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions", err)
+				return
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, newUserResponse(user))
+	}
+}
+
+// bootstrapAdmin makes sure there is an admin to manage everyone else. If
+// there is none yet, the user with the given email is made admin, and is
+// created with the given password if they do not exist. An existing user is
+// only promoted if the password matches theirs or they have verified the
+// email, so signing up with the address first is not enough to become admin.
+func bootstrapAdmin(ctx context.Context, db *database.Queries, email, password string) error {
+	admins, err := db.CountUsersWithRole(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	user, err := db.GetUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		if password == "" {
+			return fmt.Errorf("user %s does not exist and ADMIN_PASSWORD is not set", email)
+		}
+
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		user, err = db.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if !user.EmailVerifiedAt.Valid {
+		matches, err := auth.CheckPasswordHash(password, user.HashedPassword)
+		if err != nil && !errors.Is(err, auth.ErrPasswordUnset) {
+			return err
+		}
+		if password == "" || !matches {
+			return fmt.Errorf("user %s has not verified their email and ADMIN_PASSWORD does not match their password", email)
+		}
+	}
+
+	if _, err := db.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: auth.RoleAdmin,
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("Made %s the first admin", email)
+	return nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/sessions"
+)
+
+func TestMiddlewareRequireRole(t *testing.T) {
+	revokedSession := uuid.New()
+	cfg := &apiConfig{
+		tokenKeys: newTestKeyring(t),
+		sessionCache: sessions.NewRevocationCache(time.Minute, func(ctx context.Context, id uuid.UUID) (bool, error) {
+			return id == revokedSession, nil
+		}),
+	}
+
+	handler := cfg.middlewareRequireRole(auth.RoleModerator, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	accessToken := func(role string, sessionID uuid.UUID) string {
+		t.Helper()
+		token, err := cfg.tokenKeys.MakeJWT(uuid.New(), role, sessionID, time.Hour)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return token
+	}
+	appToken, err := cfg.tokenKeys.MakeClientJWT(uuid.New(), uuid.New(), uuid.NewString(), []string{auth.ScopeChirpsWrite}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "no token", token: "", want: http.StatusUnauthorized},
+		{name: "user", token: accessToken(auth.RoleUser, uuid.New()), want: http.StatusForbidden},
+		{name: "moderator", token: accessToken(auth.RoleModerator, uuid.New()), want: http.StatusNoContent},
+		{name: "admin", token: accessToken(auth.RoleAdmin, uuid.New()), want: http.StatusNoContent},
+		{name: "logged out admin", token: accessToken(auth.RoleAdmin, revokedSession), want: http.StatusUnauthorized},
+		{name: "app token", token: appToken, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
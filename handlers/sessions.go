@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/sessions"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+// sessionCacheTTL is how long another replica may keep accepting access
+// tokens of a session after it has been logged out.
+const sessionCacheTTL = 30 * time.Second
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	// ClientID is set for sessions of third-party apps the user authorized.
+	ClientID *uuid.UUID `json:"client_id,omitempty"`
+}
+
+// sessionLookup reports sessions as revoked once they are logged out or
+// gone, for example because the user was deleted.
+func sessionLookup(db *database.Queries) sessions.Lookup {
+	return func(ctx context.Context, id uuid.UUID) (bool, error) {
+		session, err := db.GetSession(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return session.RevokedAt.Valid, nil
+	}
+}
+
+func listSessions(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userSessions, err := cfg.db.ListUserSessions(req.Context(), database.ListUserSessionsParams{
+			UserID:             userIDFromContext(req.Context()),
+			ClientTokenSeconds: int32(oauthTokenExpiry.Seconds()),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not list sessions", err)
+			return
+		}
+
+		current := sessionIDFromContext(req.Context())
+		res := make([]sessionResponse, 0, len(userSessions))
+		for _, session := range userSessions {
+			item := sessionResponse{
+				ID:         session.ID,
+				CreatedAt:  session.CreatedAt,
+				LastUsedAt: session.LastUsedAt,
+				UserAgent:  session.UserAgent,
+				IP:         session.Ip,
+				Current:    session.ID == current,
+			}
+			if session.ClientID.Valid {
+				item.ClientID = &session.ClientID.UUID
+			}
+			res = append(res, item)
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, res)
+	}
+}
+
+// deleteSession logs one of the user's devices out.
+func deleteSession(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		sessionID, err := uuid.Parse(req.PathValue("sessionID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+			return
+		}
+
+		ended, err := cfg.endSession(req.Context(), userIDFromContext(req.Context()), sessionID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke session", err)
+			return
+		}
+
+		if !ended {
+			utils.RespondWithError(w, http.StatusNotFound, "Session not found", nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// endSession revokes a session of userID along with its refresh tokens. It
+// reports false if the user has no such active session.
+func (cfg *apiConfig) endSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
+	revoked, err := cfg.db.RevokeSession(ctx, database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if revoked == 0 {
+		return false, nil
+	}
+
+	cfg.sessionCache.MarkRevoked(sessionID)
+
+	if err := cfg.db.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// endUserSessions logs a user out everywhere.
+func (cfg *apiConfig) endUserSessions(ctx context.Context, userID uuid.UUID) error {
+	revoked, err := revokeUserSessions(ctx, cfg.db, userID)
+	for _, id := range revoked {
+		cfg.sessionCache.MarkRevoked(id)
+	}
+	return err
+}
+
+// revokeUserSessions revokes all sessions of a user and their refresh tokens
+// and returns the IDs of the sessions, which the caller has to mark revoked
+// in the session cache. It is separate from endUserSessions so that it can
+// run in a transaction.
+func revokeUserSessions(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]uuid.UUID, error) {
+	revoked, err := q.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return revoked, q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// endOtherUserSessions logs a user out everywhere except in the session
+// keepID, typically the one making the request.
+func (cfg *apiConfig) endOtherUserSessions(ctx context.Context, userID, keepID uuid.UUID) error {
+	revoked, err := cfg.db.RevokeOtherUserSessions(ctx, database.RevokeOtherUserSessionsParams{
+		UserID: userID,
+		ID:     keepID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range revoked {
+		cfg.sessionCache.MarkRevoked(id)
+	}
+
+	return cfg.db.RevokeOtherUserRefreshTokens(ctx, database.RevokeOtherUserRefreshTokensParams{
+		UserID:   userID,
+		FamilyID: keepID,
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+// maxThreadDepth is how many levels of replies a thread request returns at
+// most.
+const maxThreadDepth = 100
+
+// threadChirpResponse is a chirp in a conversation. Deleted chirps that have
+// replies stay in the thread with an empty body and no author.
+type threadChirpResponse struct {
+	chirpResponse
+	Depth   int  `json:"depth"`
+	Deleted bool `json:"deleted"`
+}
+
+// getChirpThread returns the whole conversation a chirp belongs to, starting
+// from the chirp that began it. Replies follow the chirp they answer, oldest
+// first, so the list can be shown as a tree by indenting each chirp by its
+// depth.
+func getChirpThread(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		chirpUUID, err := uuid.Parse(req.PathValue("chirpID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+			return
+		}
+
+		maxDepth := maxThreadDepth
+		if s := req.URL.Query().Get("max_depth"); s != "" {
+			maxDepth, err = strconv.Atoi(s)
+			if err != nil || maxDepth < 0 || maxDepth > maxThreadDepth {
+				utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Max depth must be between 0 and %d", maxThreadDepth), err)
+				return
+			}
+		}
+
+		// Tombstones are looked up too; their threads are still there.
+		chirp, err := cfg.db.GetChrip(req.Context(), chirpUUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirp", err)
+			}
+			return
+		}
+
+		rootID := chirp.ID
+		if chirp.RootID.Valid {
+			rootID = chirp.RootID.UUID
+		}
+
+		thread, err := cfg.db.GetChirpThread(req.Context(), database.GetChirpThreadParams{
+			RootID:   rootID,
+			MaxDepth: int32(maxDepth),
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch thread", err)
+			return
+		}
+
+		chirps := make([]database.Chirp, len(thread))
+		for i, row := range thread {
+			chirps[i] = database.Chirp{
+				ID:         row.ID,
+				CreatedAt:  row.CreatedAt,
+				UpdatedAt:  row.UpdatedAt,
+				Body:       row.Body,
+				UserID:     row.UserID,
+				ParentID:   row.ParentID,
+				RootID:     row.RootID,
+				DeletedAt:  row.DeletedAt,
+				RepostOfID: row.RepostOfID,
+				QuoteOfID:  row.QuoteOfID,
+			}
+		}
+
+		chirpResponses, err := cfg.chirpResponses(req.Context(), chirps)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		response := make([]threadChirpResponse, len(thread))
+		for i, row := range thread {
+			response[i] = threadChirpResponse{
+				chirpResponse: chirpResponses[i],
+				Depth:         int(row.Depth),
+				Deleted:       row.DeletedAt.Valid,
+			}
+			if row.DeletedAt.Valid {
+				response[i].UserID = uuid.Nil
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/khizar-sudo/chirpy/internal/pagination"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+// getTimeline returns the chirps of the users the authenticated user follows
+// along with their own, newest first.
+func getTimeline(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID := userIDFromContext(req.Context())
+		query := req.URL.Query()
+
+		limit, err := parseLimit(query.Get("limit"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize), err)
+			return
+		}
+
+		scope := "timeline:" + userID.String()
+		var after *pagination.Cursor
+		if s := query.Get("cursor"); s != "" {
+			cursor, err := pagination.Decode(s, scope, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+				return
+			}
+			after = &cursor
+		}
+
+		// Fetch one extra row to find out whether there is a next page.
+		chirps, err := cfg.timelines.Page(req.Context(), userID, after, int32(limit+1))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch timeline", err)
+			return
+		}
+
+		if len(chirps) > limit {
+			chirps = chirps[:limit]
+			last := chirps[len(chirps)-1]
+			next, err := pagination.Encode(pagination.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.ID,
+				Scope:     scope,
+			}, cfg.cursorSecret)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not make cursor", err)
+				return
+			}
+			setNextLink(w, req, next)
+		}
+
+		response, err := cfg.chirpResponses(req.Context(), chirps)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch likes", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -17,10 +20,69 @@ type userRequest struct {
 }
 
 type userResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `json:"email"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Role:          user.Role,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+}
+
+// userProfileResponse is the public view of a user. It leaves out the email
+// address and anything else only the user should see.
+type userProfileResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Followers   int64     `json:"followers"`
+	Following   int64     `json:"following"`
+}
+
+func getUserProfile(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, err := uuid.Parse(req.PathValue("userID"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(req.Context(), userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch user", err)
+			}
+			return
+		}
+
+		counts, err := cfg.db.GetFollowCounts(req.Context(), user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch user", err)
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, userProfileResponse{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			IsChirpyRed: user.IsChirpyRed,
+			Followers:   counts.Followers,
+			Following:   counts.Following,
+		})
+	}
 }
 
 func createUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
@@ -38,6 +100,10 @@ func createUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		if !cfg.checkPasswordPolicy(w, body.Password, body.Email) {
+			return
+		}
+
 		hashPassword, err := auth.HashPassword(body.Password)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
@@ -53,11 +119,76 @@ func createUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		utils.RespondWithJSON(w, http.StatusCreated, userResponse{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-		})
+		if _, err := cfg.sendEmailVerification(req.Context(), user); err != nil {
+			log.Printf("Could not send verification email to user %s: %s", user.ID, err)
+		}
+
+		utils.RespondWithJSON(w, http.StatusCreated, newUserResponse(user))
 	})
 }
+
+// updateUser replaces the email and password of the authenticated user. The
+// user's other sessions and all of their API keys are revoked so other devices
+// must log in again.
+func updateUser(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID := userIDFromContext(req.Context())
+
+		body := userRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if body.Email == "" || body.Password == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Email and Password required", nil)
+			return
+		}
+
+		if !cfg.checkPasswordPolicy(w, body.Password, body.Email) {
+			return
+		}
+
+		hashPassword, err := auth.HashPassword(body.Password)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+
+		user, err := cfg.db.UpdateUser(req.Context(), database.UpdateUserParams{
+			ID:             userID,
+			Email:          body.Email,
+			HashedPassword: hashPassword,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not update user", err)
+			}
+			return
+		}
+
+		if err := cfg.endOtherUserSessions(req.Context(), user.ID, sessionIDFromContext(req.Context())); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions", err)
+			return
+		}
+
+		if err := cfg.db.RevokeUserAPIKeys(req.Context(), user.ID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke API keys", err)
+			return
+		}
+
+		// Changing the email clears its verification, so the new address
+		// needs a verification email of its own.
+		if !user.EmailVerifiedAt.Valid {
+			if _, err := cfg.sendEmailVerification(req.Context(), user); err != nil {
+				log.Printf("Could not send verification email to user %s: %s", user.ID, err)
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, newUserResponse(user))
+	}
+}
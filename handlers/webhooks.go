@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/auth"
+	"github.com/khizar-sudo/chirpy/internal/utils"
+)
+
+type polkaWebhookRequest struct {
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+	} `json:"data"`
+}
+
+// polkaWebhook handles payment events from Polka. Polka retries deliveries
+// until it sees a 2XX, so applying an event that was already applied must
+// succeed without changing anything.
+func polkaWebhook(cfg *apiConfig) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		apiKey, err := auth.GetAPIKey(req.Header)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "No API key provided", err)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid API key", nil)
+			return
+		}
+
+		body := polkaWebhookRequest{}
+
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+
+		if body.Event != "user.upgraded" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		_, err = cfg.db.UpgradeUserToChirpyRed(req.Context(), body.Data.UserID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not upgrade user", err)
+				return
+			}
+
+			// Either the user does not exist or this is a retried delivery
+			// for a user that is already upgraded.
+			if _, err := cfg.db.GetUserByID(req.Context(), body.Data.UserID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					utils.RespondWithError(w, http.StatusNotFound, "User not found", nil)
+				} else {
+					utils.RespondWithError(w, http.StatusInternalServerError, "Something went wrong", err)
+				}
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
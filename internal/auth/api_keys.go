@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Personal API keys look like chirpy_<prefix>_<secret>. The prefix is stored
+// in the clear to look the key up and to show the user which key is which;
+// only a hash of the whole key is stored.
+const (
+	apiKeyPrefix      = "chirpy_"
+	apiKeyPrefixBytes = 8
+	apiKeySecretBytes = 32
+)
+
+// Scopes limit what an API key or a third-party app token can do. Access
+// tokens from logging in are not limited by them. There is deliberately no
+// scope for changing credentials: that needs a login session.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeFollowsWrite = "follows:write"
+	ScopeLikesWrite   = "likes:write"
+)
+
+// Scopes lists every scope that can be granted to an API key or app.
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeFollowsWrite, ScopeLikesWrite}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// MakeAPIKey returns a new personal API key along with its lookup prefix.
+// The prefix is long enough that keys never collide on it in practice.
+func MakeAPIKey() (key, prefix string, err error) {
+	raw := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	prefix = hex.EncodeToString(raw[:apiKeyPrefixBytes])
+	secret := hex.EncodeToString(raw[apiKeyPrefixBytes:])
+	return apiKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+// IsAPIKey reports whether credentials look like a personal API key rather
+// than a JWT.
+func IsAPIKey(credentials string) bool {
+	return strings.HasPrefix(credentials, apiKeyPrefix)
+}
+
+// ParseAPIKey returns the lookup prefix of a personal API key.
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
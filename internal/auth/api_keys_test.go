@@ -0,0 +1,67 @@
+package auth
+
+import "testing"
+
+func TestMakeAPIKey(t *testing.T) {
+	t.Run("creates a key that parses back to its prefix", func(t *testing.T) {
+		key, prefix, err := MakeAPIKey()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if !IsAPIKey(key) {
+			t.Errorf("expected %q to be recognized as an API key", key)
+		}
+
+		parsed, ok := ParseAPIKey(key)
+		if !ok {
+			t.Fatalf("failed to parse %q", key)
+		}
+
+		if parsed != prefix {
+			t.Errorf("expected prefix %s, got %s", prefix, parsed)
+		}
+
+		if len(prefix) != 2*apiKeyPrefixBytes {
+			t.Errorf("expected a prefix of %d characters, got %q", 2*apiKeyPrefixBytes, prefix)
+		}
+	})
+
+	t.Run("creates different keys on each call", func(t *testing.T) {
+		key1, _, err := MakeAPIKey()
+		if err != nil {
+			t.Fatalf("failed to create key1: %v", err)
+		}
+
+		key2, _, err := MakeAPIKey()
+		if err != nil {
+			t.Fatalf("failed to create key2: %v", err)
+		}
+
+		if key1 == key2 {
+			t.Error("expected different keys on each call")
+		}
+	})
+}
+
+func TestParseAPIKey(t *testing.T) {
+	for _, key := range []string{"", "abc", "chirpy_", "chirpy_abc", "chirpy__abc", "chirpy_abc_", "eyJhbGciOiJIUzI1NiJ9.e30.abc"} {
+		if _, ok := ParseAPIKey(key); ok {
+			t.Errorf("expected %q not to parse", key)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("expected %s to be valid", scope)
+		}
+	}
+
+	for _, scope := range []string{"admin", "users:write"} {
+		if ValidScope(scope) {
+			t.Errorf("expected %s not to be a valid scope", scope)
+		}
+	}
+}
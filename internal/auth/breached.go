@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// breachedPrefixLength is the length of the SHA-1 prefix a breached password
+// lookup reveals, as in the k-anonymity range API of Have I Been Pwned.
+const breachedPrefixLength = 5
+
+// BreachedPasswords reports whether a password is known from a breach.
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
+
+// BreachedList is a breached password list held in memory. Like a
+// k-anonymity range API it is keyed by the first five characters of the
+// SHA-1 of each password, so a lookup only ever works on a small range of
+// hash suffixes.
+type BreachedList struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedList reads a breached password list from path. See
+// ReadBreachedList for the format.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBreachedList(f)
+}
+
+// ReadBreachedList reads a list of SHA-1 password hashes, one hex hash per
+// line, optionally followed by a colon and a count as in the downloads of
+// Have I Been Pwned. Blank lines and lines starting with # are ignored.
+func ReadBreachedList(r io.Reader) (*BreachedList, error) {
+	list := &BreachedList{ranges: map[string][]string{}}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+
+		prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+		list.ranges[prefix] = append(list.ranges[prefix], suffix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range list.ranges {
+		slices.Sort(suffixes)
+	}
+
+	return list, nil
+}
+
+// Range returns the hash suffixes of breached passwords whose SHA-1 starts
+// with prefix.
+func (l *BreachedList) Range(prefix string) []string {
+	return l.ranges[strings.ToUpper(prefix)]
+}
+
+func (l *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, found := slices.BinarySearch(l.Range(hash[:breachedPrefixLength]), hash[breachedPrefixLength:])
+	return found, nil
+}
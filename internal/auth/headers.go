@@ -6,12 +6,62 @@ import (
 	"strings"
 )
 
+const (
+	SchemeBearer = "Bearer"
+	SchemeAPIKey = "ApiKey"
+)
+
+var (
+	ErrNoAuthHeader          = errors.New("no authorization header")
+	ErrMalformedAuthHeader   = errors.New("malformed authorization header")
+	ErrUnsupportedAuthScheme = errors.New("unsupported authorization scheme")
+)
+
+// knownSchemes maps lower-cased scheme names to their canonical spelling.
+var knownSchemes = map[string]string{
+	"bearer": SchemeBearer,
+	"apikey": SchemeAPIKey,
+}
+
+// GetAuthorization splits the Authorization header into its scheme and
+// credentials. Schemes are case-insensitive; known schemes are returned in
+// their canonical spelling and any other scheme is returned as sent.
+func GetAuthorization(headers http.Header) (scheme, credentials string, err error) {
+	header := headers.Get("Authorization")
+	if strings.TrimSpace(header) == "" {
+		return "", "", ErrNoAuthHeader
+	}
+
+	parts := strings.Fields(header)
+	if len(parts) != 2 {
+		return "", "", ErrMalformedAuthHeader
+	}
+
+	scheme = parts[0]
+	if canonical, ok := knownSchemes[strings.ToLower(scheme)]; ok {
+		scheme = canonical
+	}
+
+	return scheme, parts[1], nil
+}
+
 func GetBearerToken(headers http.Header) (string, error) {
-	token := strings.Split(headers.Get("Authorization"), " ")[1]
+	return getCredentials(headers, SchemeBearer)
+}
+
+func GetAPIKey(headers http.Header) (string, error) {
+	return getCredentials(headers, SchemeAPIKey)
+}
+
+func getCredentials(headers http.Header, want string) (string, error) {
+	scheme, credentials, err := GetAuthorization(headers)
+	if err != nil {
+		return "", err
+	}
 
-	if token == "" {
-		return token, errors.New("No token found")
+	if scheme != want {
+		return "", ErrUnsupportedAuthScheme
 	}
 
-	return token, nil
-}
\ No newline at end of file
+	return credentials, nil
+}
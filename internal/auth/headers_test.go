@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGetAuthorization(t *testing.T) {
+	t.Run("parses scheme and credentials", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+		scheme, credentials, err := GetAuthorization(headers)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if scheme != "Basic" || credentials != "dXNlcjpwYXNz" {
+			t.Errorf("expected Basic dXNlcjpwYXNz, got %s %s", scheme, credentials)
+		}
+	})
+
+	t.Run("canonicalizes known schemes", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "bEaReR abc")
+
+		scheme, _, err := GetAuthorization(headers)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if scheme != SchemeBearer {
+			t.Errorf("expected scheme %s, got %s", SchemeBearer, scheme)
+		}
+	})
+
+	t.Run("fails without a header", func(t *testing.T) {
+		_, _, err := GetAuthorization(http.Header{})
+		if !errors.Is(err, ErrNoAuthHeader) {
+			t.Fatalf("expected ErrNoAuthHeader, got %v", err)
+		}
+	})
+
+	t.Run("fails with malformed headers", func(t *testing.T) {
+		for _, value := range []string{"Bearer", "Bearer ", "abc", "Bearer a b"} {
+			headers := http.Header{}
+			headers.Set("Authorization", value)
+
+			_, _, err := GetAuthorization(headers)
+			if !errors.Is(err, ErrMalformedAuthHeader) {
+				t.Errorf("expected ErrMalformedAuthHeader for %q, got %v", value, err)
+			}
+		}
+	})
+}
+
+func TestGetBearerToken(t *testing.T) {
+	t.Run("returns the token", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "bearer abc.def.ghi")
+
+		token, err := GetBearerToken(headers)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if token != "abc.def.ghi" {
+			t.Errorf("expected abc.def.ghi, got %s", token)
+		}
+	})
+
+	t.Run("fails with another scheme", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "ApiKey abc")
+
+		_, err := GetBearerToken(headers)
+		if !errors.Is(err, ErrUnsupportedAuthScheme) {
+			t.Fatalf("expected ErrUnsupportedAuthScheme, got %v", err)
+		}
+	})
+}
+
+func TestGetAPIKey(t *testing.T) {
+	t.Run("returns the key", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "APIKEY f271c81ff7084ee5b99a5091b42d486e")
+
+		key, err := GetAPIKey(headers)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if key != "f271c81ff7084ee5b99a5091b42d486e" {
+			t.Errorf("expected f271c81ff7084ee5b99a5091b42d486e, got %s", key)
+		}
+	})
+
+	t.Run("fails with another scheme", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Authorization", "Bearer abc")
+
+		_, err := GetAPIKey(headers)
+		if !errors.Is(err, ErrUnsupportedAuthScheme) {
+			t.Fatalf("expected ErrUnsupportedAuthScheme, got %v", err)
+		}
+	})
+}
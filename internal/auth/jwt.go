@@ -1,45 +0,0 @@
-package auth
-
-import (
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
-)
-
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	timeNow := time.Now().UTC()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(timeNow),
-		ExpiresAt: jwt.NewNumericDate(timeNow.Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	signed, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-
-	return signed, nil
-}
-
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-
-	subject, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-
-	userID, err := uuid.Parse(subject)
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-
-	return userID, nil
-}
@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MinSecretLength is the shortest HMAC secret a Keyring accepts. HS256 keys
+// shorter than the hash output weaken the signature.
+const MinSecretLength = 32
+
+// MinRSABits is the smallest RSA modulus a Keyring accepts.
+const MinRSABits = 2048
+
+var (
+	ErrUnknownKey    = errors.New("token signed with unknown key")
+	ErrWrongTokenUse = errors.New("token cannot be used here")
+)
+
+const (
+	tokenUseAccess     = "access"
+	tokenUseMFAPending = "mfa_pending"
+)
+
+// Claims are the claims of tokens issued by a Keyring.
+type Claims struct {
+	jwt.RegisteredClaims
+	// TokenUse tells access tokens apart from tokens that only prove part of
+	// a login, such as a correct password that still needs a second factor.
+	TokenUse string `json:"token_use,omitempty"`
+	// Role is the role of the user when the token was issued. Only access
+	// tokens carry a role.
+	Role string `json:"role,omitempty"`
+	// SessionID is the login session an access token belongs to, so that
+	// logging a session out also rejects its access tokens.
+	SessionID string `json:"sid,omitempty"`
+	// ClientID and Scope are set on access tokens issued to third-party
+	// apps, which may only do what the scopes allow.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// Scopes returns the scopes of a token issued to a third-party app.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Keyring signs tokens with its current key and validates tokens signed with
+// the current key or any previous one. Every token carries the ID of its key
+// in the kid header, so old keys can be kept around for a rotation window
+// until all tokens signed with them have expired.
+//
+// Each key is bound to a single algorithm. A token is only accepted if its
+// alg header matches the algorithm of the key named by its kid, so a token
+// cannot, for example, be HMAC-signed using an RSA public key as the secret.
+type Keyring struct {
+	currentID string
+	keys      map[string]signingKey
+}
+
+type signingKey struct {
+	method jwt.SigningMethod
+	// private is nil for previous keys that were loaded from a public key.
+	private any
+	public  any
+	// jwk is nil for HMAC keys, which must never be published.
+	jwk *JWK
+}
+
+// JWK is the public half of an asymmetric signing key as described in
+// RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeyring builds a keyring of HS256 secrets.
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]signingKey{}}
+
+	id, err := k.addSecret(current)
+	if err != nil {
+		return nil, err
+	}
+	k.currentID = id
+
+	for _, secret := range previous {
+		if _, err := k.addSecret(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// NewKeyringFromPEM builds a keyring of Ed25519 (EdDSA) or RSA (RS256) keys.
+// The current key must be a private key in PKCS #8 or PKCS #1 form. Previous
+// keys are only used for validation, so public keys are enough.
+func NewKeyringFromPEM(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string]signingKey{}}
+
+	id, err := k.addPEM(current)
+	if err != nil {
+		return nil, err
+	}
+	if k.keys[id].private == nil {
+		return nil, errors.New("current signing key must be a private key")
+	}
+	k.currentID = id
+
+	for _, data := range previous {
+		if _, err := k.addPEM(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) addSecret(secret string) (string, error) {
+	if len(secret) < MinSecretLength {
+		return "", fmt.Errorf("token secret must be at least %d bytes", MinSecretLength)
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	id := hex.EncodeToString(sum[:8])
+	k.keys[id] = signingKey{
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+	return id, nil
+}
+
+func (k *Keyring) addPEM(data []byte) (string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", errors.New("no PEM block found in signing key")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return "", fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	key := signingKey{}
+	switch v := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.private, key.public = v, v.Public()
+	case *rsa.PrivateKey:
+		key.private, key.public = v, v.Public()
+	case ed25519.PublicKey, *rsa.PublicKey:
+		key.public = v
+	default:
+		return "", fmt.Errorf("unsupported signing key type %T", parsed)
+	}
+
+	switch pub := key.public.(type) {
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = &JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < MinRSABits {
+			return "", fmt.Errorf("RSA signing keys must be at least %d bits", MinRSABits)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.jwk = &JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	}
+
+	id, err := thumbprint(key.jwk)
+	if err != nil {
+		return "", err
+	}
+	key.jwk.Use = "sig"
+	key.jwk.Alg = key.method.Alg()
+	key.jwk.Kid = id
+	k.keys[id] = key
+	return id, nil
+}
+
+// thumbprint computes the RFC 7638 thumbprint of a public key, which is used
+// as its kid.
+func thumbprint(jwk *JWK) (string, error) {
+	var members any
+	switch jwk.Kty {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// JWKS returns the public keys that other services need to verify tokens
+// issued by this keyring. HMAC secrets are never included.
+func (k *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		if key.jwk != nil {
+			set.Keys = append(set.Keys, *key.jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// MakeJWT issues an access token for userID with the given role, belonging
+// to the given session.
+func (k *Keyring) MakeJWT(userID uuid.UUID, role string, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := Claims{TokenUse: tokenUseAccess, Role: role}
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
+	}
+	return k.sign(userID, claims, expiresIn)
+}
+
+// MakeClientJWT issues an access token for userID to the third-party app
+// clientID, limited to scopes.
+func (k *Keyring) MakeClientJWT(userID, sessionID uuid.UUID, clientID string, scopes []string, expiresIn time.Duration) (string, error) {
+	return k.sign(userID, Claims{
+		TokenUse:  tokenUseAccess,
+		Role:      RoleUser,
+		SessionID: sessionID.String(),
+		ClientID:  clientID,
+		Scope:     strings.Join(scopes, " "),
+	}, expiresIn)
+}
+
+// ValidateJWT validates an access token and returns the user it was issued
+// to.
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := k.ParseAccessToken(tokenString)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return uuid.Parse(claims.Subject)
+}
+
+// ParseAccessToken validates an access token and returns its claims.
+func (k *Keyring) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims, err := k.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before token_use was introduced are access tokens.
+	if claims.TokenUse != tokenUseAccess && claims.TokenUse != "" {
+		return nil, ErrWrongTokenUse
+	}
+
+	// Tokens issued before roles were introduced belong to plain users.
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+
+	return claims, nil
+}
+
+// MakeMFAToken issues a token proving that userID entered a correct password
+// and still has to provide a second factor. It is not an access token.
+func (k *Keyring) MakeMFAToken(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return k.sign(userID, Claims{TokenUse: tokenUseMFAPending}, expiresIn)
+}
+
+func (k *Keyring) ValidateMFAToken(tokenString string) (uuid.UUID, error) {
+	claims, err := k.parse(tokenString)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if claims.TokenUse != tokenUseMFAPending {
+		return uuid.UUID{}, ErrWrongTokenUse
+	}
+
+	return uuid.Parse(claims.Subject)
+}
+
+// sign issues a token for userID with the registered claims filled in.
+func (k *Keyring) sign(userID uuid.UUID, claims Claims, expiresIn time.Duration) (string, error) {
+	key := k.keys[k.currentID]
+
+	timeNow := time.Now().UTC()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(timeNow),
+		ExpiresAt: jwt.NewNumericDate(timeNow.Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = k.currentID
+
+	return token.SignedString(key.private)
+}
+
+func (k *Keyring) parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		id, _ := token.Header["kid"].(string)
+		key, ok := k.keys[id]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), id)
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+		jwt.SigningMethodRS256.Alg(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,403 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestKeyring(t *testing.T) {
+	oldSecret := strings.Repeat("o", MinSecretLength)
+	newSecret := strings.Repeat("n", MinSecretLength)
+
+	t.Run("rejects short secrets", func(t *testing.T) {
+		if _, err := NewKeyring("too-short"); err == nil {
+			t.Fatal("expected error for short current secret, got nil")
+		}
+
+		if _, err := NewKeyring(newSecret, "too-short"); err == nil {
+			t.Fatal("expected error for short previous secret, got nil")
+		}
+	})
+
+	t.Run("validates tokens signed with the current key", func(t *testing.T) {
+		keys, err := NewKeyring(newSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		userID := uuid.New()
+		token, err := keys.MakeJWT(userID, RoleUser, uuid.Nil, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		validatedUserID, err := keys.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if validatedUserID != userID {
+			t.Errorf("expected user ID %s, got %s", userID, validatedUserID)
+		}
+	})
+
+	t.Run("validates tokens signed with a previous key", func(t *testing.T) {
+		before, err := NewKeyring(oldSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		after, err := NewKeyring(newSecret, oldSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		userID := uuid.New()
+		token, err := before.MakeJWT(userID, RoleUser, uuid.Nil, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		validatedUserID, err := after.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if validatedUserID != userID {
+			t.Errorf("expected user ID %s, got %s", userID, validatedUserID)
+		}
+	})
+
+	t.Run("rejects tokens signed with a retired key", func(t *testing.T) {
+		before, err := NewKeyring(oldSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		after, err := NewKeyring(newSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		token, err := before.MakeJWT(uuid.New(), RoleUser, uuid.Nil, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		if _, err := after.ValidateJWT(token); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("expected ErrUnknownKey, got %v", err)
+		}
+	})
+
+	t.Run("rejects tokens without a key ID", func(t *testing.T) {
+		keys, err := NewKeyring(newSecret)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).SignedString([]byte(newSecret))
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		if _, err := keys.ValidateJWT(token); err == nil {
+			t.Fatal("expected error for token without kid, got nil")
+		}
+	})
+}
+
+func TestKeyringFromPEM(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatalf("failed to marshal ed25519 key: %v", err)
+	}
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)})
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal rsa public key: %v", err)
+	}
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublicDER})
+
+	for name, keyPEM := range map[string][]byte{"EdDSA": edPEM, "RS256": rsaPEM} {
+		t.Run("signs and validates with "+name, func(t *testing.T) {
+			keys, err := NewKeyringFromPEM(keyPEM)
+			if err != nil {
+				t.Fatalf("failed to create keyring: %v", err)
+			}
+
+			userID := uuid.New()
+			token, err := keys.MakeJWT(userID, RoleUser, uuid.Nil, time.Hour)
+			if err != nil {
+				t.Fatalf("failed to create token: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+			if parsed.Method.Alg() != name {
+				t.Errorf("expected alg %s, got %s", name, parsed.Method.Alg())
+			}
+
+			validatedUserID, err := keys.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if validatedUserID != userID {
+				t.Errorf("expected user ID %s, got %s", userID, validatedUserID)
+			}
+		})
+	}
+
+	t.Run("rejects a public key as the current key", func(t *testing.T) {
+		if _, err := NewKeyringFromPEM(rsaPublicPEM); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+
+	t.Run("validates tokens signed with a previous public key", func(t *testing.T) {
+		before, err := NewKeyringFromPEM(rsaPEM)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		after, err := NewKeyringFromPEM(edPEM, rsaPublicPEM)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		token, err := before.MakeJWT(uuid.New(), RoleUser, uuid.Nil, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		if _, err := after.ValidateJWT(token); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("publishes public keys", func(t *testing.T) {
+		keys, err := NewKeyringFromPEM(edPEM, rsaPublicPEM)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		set := keys.JWKS()
+		if len(set.Keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(set.Keys))
+		}
+		for _, jwk := range set.Keys {
+			if jwk.Kid == "" || jwk.Use != "sig" {
+				t.Errorf("expected kid and use to be set, got %+v", jwk)
+			}
+		}
+	})
+
+	t.Run("does not publish HMAC secrets", func(t *testing.T) {
+		keys, err := NewKeyring(strings.Repeat("s", MinSecretLength))
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		if set := keys.JWKS(); len(set.Keys) != 0 {
+			t.Errorf("expected no keys, got %+v", set.Keys)
+		}
+	})
+
+	t.Run("rejects alg confusion", func(t *testing.T) {
+		keys, err := NewKeyringFromPEM(rsaPEM)
+		if err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+
+		kid := keys.JWKS().Keys[0].Kid
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+		forged.Header["kid"] = kid
+		signed, err := forged.SignedString(rsaPublicPEM)
+		if err != nil {
+			t.Fatalf("failed to sign forged token: %v", err)
+		}
+
+		if _, err := keys.ValidateJWT(signed); err == nil {
+			t.Fatal("expected error for HS256 token using an RSA key ID, got nil")
+		}
+	})
+}
+
+func TestKeyringMFAToken(t *testing.T) {
+	keys, err := NewKeyring(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	t.Run("validates MFA tokens", func(t *testing.T) {
+		userID := uuid.New()
+		token, err := keys.MakeMFAToken(userID, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		validatedUserID, err := keys.ValidateMFAToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if validatedUserID != userID {
+			t.Errorf("expected user ID %s, got %s", userID, validatedUserID)
+		}
+	})
+
+	t.Run("MFA tokens are not access tokens", func(t *testing.T) {
+		token, err := keys.MakeMFAToken(uuid.New(), time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		if _, err := keys.ValidateJWT(token); !errors.Is(err, ErrWrongTokenUse) {
+			t.Fatalf("expected ErrWrongTokenUse, got %v", err)
+		}
+	})
+
+	t.Run("access tokens are not MFA tokens", func(t *testing.T) {
+		token, err := keys.MakeJWT(uuid.New(), RoleUser, uuid.Nil, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		if _, err := keys.ValidateMFAToken(token); !errors.Is(err, ErrWrongTokenUse) {
+			t.Fatalf("expected ErrWrongTokenUse, got %v", err)
+		}
+	})
+}
+
+func TestKeyringRoleClaim(t *testing.T) {
+	keys, err := NewKeyring(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	t.Run("access tokens carry the role", func(t *testing.T) {
+		token, err := keys.MakeJWT(uuid.New(), RoleModerator, uuid.Nil, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		claims, err := keys.ParseAccessToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if claims.Role != RoleModerator {
+			t.Errorf("expected role %s, got %s", RoleModerator, claims.Role)
+		}
+	})
+
+	t.Run("tokens without a role belong to plain users", func(t *testing.T) {
+		token, err := keys.MakeJWT(uuid.New(), "", uuid.Nil, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		claims, err := keys.ParseAccessToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if claims.Role != RoleUser {
+			t.Errorf("expected role %s, got %s", RoleUser, claims.Role)
+		}
+	})
+}
+
+func TestKeyringSessionClaim(t *testing.T) {
+	keys, err := NewKeyring(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	t.Run("access tokens carry the session", func(t *testing.T) {
+		sessionID := uuid.New()
+		token, err := keys.MakeJWT(uuid.New(), RoleUser, sessionID, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		claims, err := keys.ParseAccessToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if claims.SessionID != sessionID.String() {
+			t.Errorf("expected session %s, got %s", sessionID, claims.SessionID)
+		}
+	})
+
+	t.Run("tokens without a session leave sid out", func(t *testing.T) {
+		token, err := keys.MakeJWT(uuid.New(), RoleUser, uuid.Nil, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+
+		claims, err := keys.ParseAccessToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if claims.SessionID != "" {
+			t.Errorf("expected no session, got %s", claims.SessionID)
+		}
+	})
+}
+
+func TestKeyringClientJWT(t *testing.T) {
+	keys, err := NewKeyring(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	userID, sessionID := uuid.New(), uuid.New()
+	token, err := keys.MakeClientJWT(userID, sessionID, "partner-app", []string{ScopeChirpsRead, ScopeChirpsWrite}, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	claims, err := keys.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims.ClientID != "partner-app" {
+		t.Errorf("expected client partner-app, got %s", claims.ClientID)
+	}
+	if scopes := claims.Scopes(); len(scopes) != 2 || scopes[0] != ScopeChirpsRead || scopes[1] != ScopeChirpsWrite {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+	if claims.Role != RoleUser {
+		t.Errorf("expected role %s, got %s", RoleUser, claims.Role)
+	}
+	if claims.SessionID != sessionID.String() {
+		t.Errorf("expected session %s, got %s", sessionID, claims.SessionID)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules of the password policy, as reported in PolicyViolation.
+const (
+	RuleMinLength  = "min_length"
+	RuleMinEntropy = "min_entropy"
+	RuleNotEmail   = "not_email"
+	RuleBreached   = "breached"
+)
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+	MinLength int
+	// MinEntropyBits is compared against EstimateEntropy.
+	MinEntropyBits float64
+	// Breached, if set, rejects passwords known from breaches.
+	Breached BreachedPasswords
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MinEntropyBits: 30,
+}
+
+// PolicyViolation is a rule that a password failed.
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Check returns every rule that password fails for the account with the
+// given email, or nil if it passes all of them.
+func (p PasswordPolicy) Check(password, email string) ([]PolicyViolation, error) {
+	var violations []PolicyViolation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: "Password is too short",
+		})
+	}
+
+	if EstimateEntropy(password) < p.MinEntropyBits {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinEntropy,
+			Message: "Password is too easy to guess",
+		})
+	}
+
+	if isEmailPassword(password, email) {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleNotEmail,
+			Message: "Password must not be your email address",
+		})
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, PolicyViolation{
+				Rule:    RuleBreached,
+				Message: "Password has appeared in a data breach",
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// EstimateEntropy is a rough estimate of the bits of entropy in password,
+// based on how evenly it uses its characters. Repeated characters add
+// little, so "aaaaaaaa" scores 0 while a random string scores close to
+// length times the log of its alphabet.
+func EstimateEntropy(password string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range password {
+		counts[r]++
+		n++
+	}
+
+	var perChar float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+
+	return perChar * float64(n)
+}
+
+// isEmailPassword reports whether password is the email address or the part
+// of it before the @.
+func isEmailPassword(password, email string) bool {
+	if email == "" {
+		return false
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	return strings.EqualFold(password, email) || strings.EqualFold(password, local)
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func violatedRules(t *testing.T, policy PasswordPolicy, password, email string) []string {
+	t.Helper()
+
+	violations, err := policy.Check(password, email)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var rules []string
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	breached, err := ReadBreachedList(strings.NewReader("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"))
+	if err != nil {
+		t.Fatalf("failed to read breached list: %v", err)
+	}
+
+	policy := DefaultPasswordPolicy
+	policy.Breached = breached
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{"accepts a strong password", "correct horse battery staple", "walt@breakingbad.com", nil},
+		{"rejects a short password", "a", "walt@breakingbad.com", []string{RuleMinLength, RuleMinEntropy}},
+		{"rejects a repetitive password", "aaaaaaaaaaaaaaaa", "walt@breakingbad.com", []string{RuleMinEntropy}},
+		{"rejects the email address", "Walt@BreakingBad.com", "walt@breakingbad.com", []string{RuleNotEmail}},
+		{"rejects the local part of the email address", "heisenberg1958", "heisenberg1958@breakingbad.com", []string{RuleNotEmail}},
+		{"rejects a breached password", "password", "walt@breakingbad.com", []string{RuleMinEntropy, RuleBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violatedRules(t, policy, tt.password, tt.email)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected violations %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEstimateEntropy(t *testing.T) {
+	if got := EstimateEntropy(""); got != 0 {
+		t.Errorf("expected 0 bits for an empty password, got %v", got)
+	}
+
+	if got := EstimateEntropy("aaaaaaaa"); got != 0 {
+		t.Errorf("expected 0 bits for a single repeated character, got %v", got)
+	}
+
+	if got := EstimateEntropy("abcdefgh"); got != 24 {
+		t.Errorf("expected 24 bits for 8 distinct characters, got %v", got)
+	}
+}
+
+func TestBreachedList(t *testing.T) {
+	input := `# a comment
+
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+7c4a8d09ca3762af61e59520943dc26494f8941b
+`
+	list, err := ReadBreachedList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("finds breached passwords", func(t *testing.T) {
+		for _, password := range []string{"password", "123456"} {
+			found, err := list.Contains(password)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !found {
+				t.Errorf("expected %q to be breached", password)
+			}
+		}
+	})
+
+	t.Run("does not find other passwords", func(t *testing.T) {
+		found, err := list.Contains("correct horse battery staple")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if found {
+			t.Error("expected password not to be breached")
+		}
+	})
+
+	t.Run("looks up ranges by prefix", func(t *testing.T) {
+		suffixes := list.Range("5baa6")
+		if len(suffixes) != 1 || suffixes[0] != "1E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+			t.Errorf("unexpected range %v", suffixes)
+		}
+	})
+
+	t.Run("rejects lines that are not hashes", func(t *testing.T) {
+		if _, err := ReadBreachedList(strings.NewReader("password\n")); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -1,19 +1,102 @@
 package auth
 
-import "github.com/alexedwards/argon2id"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// UnsetPasswordHash is the hashed_password of users without a password, such
+// as users created before passwords existed or through an identity provider.
+// They have no password until they reset it.
+const UnsetPasswordHash = "unset"
+
+var ErrPasswordUnset = errors.New("password has not been set")
+
+// PasswordParams are the argon2id cost parameters for new password hashes.
+type PasswordParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Minimums below which SetPasswordParams refuses to go.
+const (
+	MinPasswordMemory     = 19 * 1024
+	MinPasswordIterations = 1
+)
+
+var DefaultPasswordParams = PasswordParams{
+	Memory:      argon2id.DefaultParams.Memory,
+	Iterations:  argon2id.DefaultParams.Iterations,
+	Parallelism: argon2id.DefaultParams.Parallelism,
+}
+
+var passwordParams = DefaultPasswordParams
+
+// SetPasswordParams changes the cost of hashes made by HashPassword. Hashes
+// made with weaker parameters are reported by NeedsRehash. It is not safe to
+// call while passwords are being hashed, so call it on startup.
+func SetPasswordParams(params PasswordParams) error {
+	if params.Memory < MinPasswordMemory {
+		return fmt.Errorf("argon2id memory must be at least %d KiB", MinPasswordMemory)
+	}
+	if params.Iterations < MinPasswordIterations {
+		return fmt.Errorf("argon2id iterations must be at least %d", MinPasswordIterations)
+	}
+	if params.Parallelism < 1 {
+		return errors.New("argon2id parallelism must be at least 1")
+	}
+
+	passwordParams = params
+	return nil
+}
+
+func currentParams() *argon2id.Params {
+	return &argon2id.Params{
+		Memory:      passwordParams.Memory,
+		Iterations:  passwordParams.Iterations,
+		Parallelism: passwordParams.Parallelism,
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+}
 
 func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	hash, err := argon2id.CreateHash(password, currentParams())
 	if err != nil {
 		return "", err
 	}
 	return hash, nil
 }
 
+// CheckPasswordHash reports whether password matches hash. It returns
+// ErrPasswordUnset for accounts that never had a password.
 func CheckPasswordHash(password, hash string) (bool, error) {
+	if hash == UnsetPasswordHash {
+		return false, ErrPasswordUnset
+	}
+
 	check, err := argon2id.ComparePasswordAndHash(password, hash)
 	if err != nil {
 		return false, err
 	}
 	return check, nil
 }
+
+// NeedsRehash reports whether hash was made with weaker parameters than
+// HashPassword currently uses. Parallelism is not compared since it depends
+// on the machine rather than on the cost of guessing.
+func NeedsRehash(hash string) bool {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false
+	}
+
+	want := currentParams()
+	return params.Memory < want.Memory ||
+		params.Iterations < want.Iterations ||
+		params.KeyLength < want.KeyLength
+}
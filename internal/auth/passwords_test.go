@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	t.Run("accepts the right password", func(t *testing.T) {
+		ok, err := CheckPasswordHash("correct horse battery staple", hash)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !ok {
+			t.Error("expected password to match")
+		}
+	})
+
+	t.Run("rejects the wrong password", func(t *testing.T) {
+		ok, err := CheckPasswordHash("wrong", hash)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if ok {
+			t.Error("expected password not to match")
+		}
+	})
+
+	t.Run("reports accounts without a password", func(t *testing.T) {
+		if _, err := CheckPasswordHash("unset", "unset"); !errors.Is(err, ErrPasswordUnset) {
+			t.Fatalf("expected ErrPasswordUnset, got %v", err)
+		}
+	})
+}
+
+func TestNeedsRehash(t *testing.T) {
+	t.Cleanup(func() { passwordParams = DefaultPasswordParams })
+
+	weak := PasswordParams{Memory: MinPasswordMemory, Iterations: 1, Parallelism: 1}
+	if err := SetPasswordParams(weak); err != nil {
+		t.Fatalf("failed to set params: %v", err)
+	}
+
+	hash, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	t.Run("hashes made with the current parameters are kept", func(t *testing.T) {
+		if NeedsRehash(hash) {
+			t.Error("expected no rehash")
+		}
+	})
+
+	t.Run("hashes made with less memory are rehashed", func(t *testing.T) {
+		if err := SetPasswordParams(PasswordParams{Memory: 2 * MinPasswordMemory, Iterations: 1, Parallelism: 1}); err != nil {
+			t.Fatalf("failed to set params: %v", err)
+		}
+		if !NeedsRehash(hash) {
+			t.Error("expected a rehash")
+		}
+	})
+
+	t.Run("hashes made with fewer iterations are rehashed", func(t *testing.T) {
+		if err := SetPasswordParams(PasswordParams{Memory: MinPasswordMemory, Iterations: 2, Parallelism: 1}); err != nil {
+			t.Fatalf("failed to set params: %v", err)
+		}
+		if !NeedsRehash(hash) {
+			t.Error("expected a rehash")
+		}
+	})
+
+	t.Run("a different parallelism alone is not weaker", func(t *testing.T) {
+		if err := SetPasswordParams(PasswordParams{Memory: MinPasswordMemory, Iterations: 1, Parallelism: 8}); err != nil {
+			t.Fatalf("failed to set params: %v", err)
+		}
+		if NeedsRehash(hash) {
+			t.Error("expected no rehash")
+		}
+	})
+
+	t.Run("the legacy unset hash is never rehashed", func(t *testing.T) {
+		if NeedsRehash("unset") {
+			t.Error("expected no rehash")
+		}
+	})
+}
+
+func TestSetPasswordParams(t *testing.T) {
+	t.Cleanup(func() { passwordParams = DefaultPasswordParams })
+
+	tests := []struct {
+		name   string
+		params PasswordParams
+	}{
+		{"too little memory", PasswordParams{Memory: MinPasswordMemory - 1, Iterations: 1, Parallelism: 1}},
+		{"no iterations", PasswordParams{Memory: MinPasswordMemory, Iterations: 0, Parallelism: 1}},
+		{"no parallelism", PasswordParams{Memory: MinPasswordMemory, Iterations: 1, Parallelism: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetPasswordParams(tt.params); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
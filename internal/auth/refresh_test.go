@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestMakeRefreshToken(t *testing.T) {
+	t.Run("creates a 256-bit hex encoded token", func(t *testing.T) {
+		token, err := MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		decoded, err := hex.DecodeString(token)
+		if err != nil {
+			t.Fatalf("expected hex encoded token, got %v", err)
+		}
+
+		if len(decoded) != 32 {
+			t.Errorf("expected 32 bytes, got %d", len(decoded))
+		}
+	})
+
+	t.Run("creates different tokens on each call", func(t *testing.T) {
+		token1, err := MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("failed to create token1: %v", err)
+		}
+
+		token2, err := MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("failed to create token2: %v", err)
+		}
+
+		if token1 == token2 {
+			t.Error("expected different tokens on each call")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package auth
+
+import "slices"
+
+// Roles in order of increasing privilege. Each role may do everything the
+// roles before it may do.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func ValidRole(role string) bool {
+	return slices.Contains(roles, role)
+}
+
+// HasRole reports whether a user with role has at least the privileges of
+// required. Unknown roles have no privileges.
+func HasRole(role, required string) bool {
+	have := slices.Index(roles, role)
+	want := slices.Index(roles, required)
+	return have != -1 && want != -1 && have >= want
+}
@@ -0,0 +1,32 @@
+package auth
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		want     bool
+	}{
+		{RoleUser, RoleUser, true},
+		{RoleUser, RoleModerator, false},
+		{RoleUser, RoleAdmin, false},
+		{RoleModerator, RoleUser, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleAdmin, RoleUser, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleAdmin, true},
+		{"", RoleUser, false},
+		{"superuser", RoleUser, false},
+		{RoleAdmin, "superuser", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+" as "+tt.required, func(t *testing.T) {
+			if got := HasRole(tt.role, tt.required); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
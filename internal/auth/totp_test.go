@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// Test vectors from RFC 4226, appendix D.
+	key := []byte("12345678901234567890")
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, want := range expected {
+		if got := hotp(key, uint64(counter), 6); got != want {
+			t.Errorf("counter %d: expected %s, got %s", counter, want, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// The SHA-1 test vectors from RFC 6238, appendix B, truncated to our six
+	// digits.
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	t.Run("accepts RFC 6238 test vectors", func(t *testing.T) {
+		for unix, code := range vectors {
+			counter, ok := ValidateTOTP(secret, code, time.Unix(unix, 0))
+			if !ok {
+				t.Errorf("expected %s to be valid at %d", code, unix)
+			}
+			if counter != unix/30 {
+				t.Errorf("expected counter %d for %s, got %d", unix/30, code, counter)
+			}
+		}
+	})
+
+	t.Run("accepts codes from adjacent periods", func(t *testing.T) {
+		counter, ok := ValidateTOTP(secret, "005924", time.Unix(1234567890+30, 0))
+		if !ok {
+			t.Error("expected code from the previous period to be valid")
+		}
+		if counter != 1234567890/30 {
+			t.Errorf("expected the counter of the previous period, got %d", counter)
+		}
+	})
+
+	t.Run("rejects codes from distant periods", func(t *testing.T) {
+		if _, ok := ValidateTOTP(secret, "005924", time.Unix(1234567890+90, 0)); ok {
+			t.Error("expected code from three periods ago to be invalid")
+		}
+	})
+
+	t.Run("rejects malformed codes", func(t *testing.T) {
+		for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+			if _, ok := ValidateTOTP(secret, code, time.Unix(59, 0)); ok {
+				t.Errorf("expected %q to be invalid", code)
+			}
+		}
+	})
+
+	t.Run("validates generated secrets", func(t *testing.T) {
+		generated, err := GenerateTOTPSecret()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		key, err := totpEncoding.DecodeString(generated)
+		if err != nil {
+			t.Fatalf("failed to decode secret: %v", err)
+		}
+
+		now := time.Now()
+		code := hotp(key, uint64(now.Unix()/30), 6)
+		if _, ok := ValidateTOTP(generated, code, now); !ok {
+			t.Error("expected current code to be valid")
+		}
+	})
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	for _, want := range []string{"otpauth://totp/Chirpy:alice@example.com", "secret=JBSWY3DPEHPK3PXP", "issuer=Chirpy"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("expected %q to contain %q", uri, want)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected recovery code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+}
@@ -7,29 +7,81 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, root_id, quote_of_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ParentID  uuid.NullUUID
+	RootID    uuid.NullUUID
+	QuoteOfID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ParentID,
+		arg.RootID,
+		arg.QuoteOfID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.DeletedAt,
+		&i.RepostOfID,
+		&i.QuoteOfID,
+	)
+	return i, err
+}
+
+const createRepost = `-- name: CreateRepost :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, repost_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+ON CONFLICT (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id
+`
+
+type CreateRepostParams struct {
+	UserID     uuid.UUID
+	RepostOfID uuid.NullUUID
+}
+
+func (q *Queries) CreateRepost(ctx context.Context, arg CreateRepostParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRepost, arg.UserID, arg.RepostOfID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,12 +89,56 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.DeletedAt,
+		&i.RepostOfID,
+		&i.QuoteOfID,
 	)
 	return i, err
 }
 
+const deleteChirp = `-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, id)
+	return err
+}
+
+const deleteRepost = `-- name: DeleteRepost :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND repost_of_id = $2
+`
+
+type DeleteRepostParams struct {
+	UserID     uuid.UUID
+	RepostOfID uuid.NullUUID
+}
+
+func (q *Queries) DeleteRepost(ctx context.Context, arg DeleteRepostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRepost, arg.UserID, arg.RepostOfID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteReposts = `-- name: DeleteReposts :exec
+DELETE FROM chirps
+WHERE repost_of_id = $1
+`
+
+func (q *Queries) DeleteReposts(ctx context.Context, repostOfID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteReposts, repostOfID)
+	return err
+}
+
 const getAllChrips = `-- name: GetAllChrips :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -61,6 +157,126 @@ func (q *Queries) GetAllChrips(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.DeletedAt,
+			&i.RepostOfID,
+			&i.QuoteOfID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpThread = `-- name: GetChirpThread :many
+WITH RECURSIVE thread AS (
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.root_id, chirps.deleted_at, chirps.repost_of_id, chirps.quote_of_id, 0 AS depth,
+           ARRAY[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] AS path
+    FROM chirps
+    WHERE chirps.id = $1
+    UNION ALL
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.root_id, chirps.deleted_at, chirps.repost_of_id, chirps.quote_of_id, thread.depth + 1,
+           thread.path || (to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text)
+    FROM chirps
+    JOIN thread ON chirps.parent_id = thread.id
+    WHERE thread.depth < $2::int
+)
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id, depth FROM thread
+ORDER BY path
+`
+
+type GetChirpThreadParams struct {
+	RootID   uuid.UUID
+	MaxDepth int32
+}
+
+type GetChirpThreadRow struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	ParentID   uuid.NullUUID
+	RootID     uuid.NullUUID
+	DeletedAt  sql.NullTime
+	RepostOfID uuid.NullUUID
+	QuoteOfID  uuid.NullUUID
+	Depth      int32
+}
+
+// Walks the conversation down from its root. path orders the chirps depth
+// first with siblings oldest first; the timestamp is fixed width so the
+// text sorts the same way the times do.
+func (q *Queries) GetChirpThread(ctx context.Context, arg GetChirpThreadParams) ([]GetChirpThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, arg.RootID, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpThreadRow
+	for rows.Next() {
+		var i GetChirpThreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.DeletedAt,
+			&i.RepostOfID,
+			&i.QuoteOfID,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+`
+
+// Looks up shared chirps to inline. Tombstones are left out so that a
+// deleted chirp does not show up with its author and likes.
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.DeletedAt,
+			&i.RepostOfID,
+			&i.QuoteOfID,
 		); err != nil {
 			return nil, err
 		}
@@ -76,7 +292,7 @@ func (q *Queries) GetAllChrips(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChrip = `-- name: GetChrip :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id FROM chirps
 WHERE id = $1
 `
 
@@ -89,6 +305,168 @@ func (q *Queries) GetChrip(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.DeletedAt,
+		&i.RepostOfID,
+		&i.QuoteOfID,
 	)
 	return i, err
 }
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id FROM chirps
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1)
+  AND ($2::timestamp IS NULL
+       OR (created_at, id) > ($2::timestamp, $3::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.DeletedAt,
+			&i.RepostOfID,
+			&i.QuoteOfID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id FROM chirps
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1)
+  AND ($2::timestamp IS NULL
+       OR (created_at, id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.DeletedAt,
+			&i.RepostOfID,
+			&i.QuoteOfID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockChirp = `-- name: LockChirp :exec
+SELECT id FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+// Holds a chirp until the end of the transaction. New replies to it wait
+// for the lock, so they cannot slip in while it is being deleted.
+func (q *Queries) LockChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockChirp, id)
+	return err
+}
+
+const pruneTombstone = `-- name: PruneTombstone :one
+DELETE FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = $1)
+RETURNING parent_id
+`
+
+// Deletes a tombstone once its last reply is gone.
+func (q *Queries) PruneTombstone(ctx context.Context, id uuid.UUID) (uuid.NullUUID, error) {
+	row := q.db.QueryRowContext(ctx, pruneTombstone, id)
+	var parent_id uuid.NullUUID
+	err := row.Scan(&parent_id)
+	return parent_id, err
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :execrows
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = $1)
+`
+
+// Chirps with replies are blanked out instead of deleted so their thread
+// stays in one piece.
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
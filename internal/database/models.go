@@ -5,23 +5,171 @@
 package database
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type AuditLog struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ActorID   uuid.NullUUID
+	Action    string
+	TargetID  uuid.UUID
+	Details   string
+}
+
 type Chirp struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	ParentID   uuid.NullUUID
+	RootID     uuid.NullUUID
+	DeletedAt  sql.NullTime
+	RepostOfID uuid.NullUUID
+	QuoteOfID  uuid.NullUUID
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+}
+
+type MaterializedTimeline struct {
+	UserID        uuid.UUID
+	CreatedAt     time.Time
+	CompleteAfter sql.NullTime
+}
+
+type MfaRecoveryCode struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+}
+
+type OidcLoginState struct {
+	StateHash    string
+	CreatedAt    time.Time
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	ReplacedBy sql.NullString
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	UserID     uuid.UUID
+	UserAgent  string
+	Ip         string
+	RevokedAt  sql.NullTime
+	ClientID   uuid.NullUUID
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Role            string
+	IsChirpyRed     bool
+	EmailVerifiedAt sql.NullTime
+	TotpSecret      sql.NullString
+	TotpEnabledAt   sql.NullTime
+	TotpLastCounter sql.NullInt64
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
 }
@@ -7,8 +7,23 @@ package database
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
 )
 
+const countUsersWithRole = `-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1
+`
+
+func (q *Queries) CountUsersWithRole(ctx context.Context, role string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersWithRole, role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -18,7 +33,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password
+RETURNING id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter
 `
 
 type CreateUserParams struct {
@@ -35,6 +50,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
 	)
 	return i, err
 }
@@ -48,8 +69,33 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableUserTOTP, id)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :execrows
+UPDATE users
+SET totp_enabled_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+`
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUserTOTP, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password FROM users
+SELECT id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter FROM users
 WHERE email = $1
 `
 
@@ -62,6 +108,248 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const getUserByEmailFold = `-- name: GetUserByEmailFold :one
+SELECT id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter FROM users
+WHERE LOWER(email) = LOWER($1)
+ORDER BY created_at
+LIMIT 1
+`
+
+// Looks a user up by email ignoring case, oldest account first, for
+// matching addresses that come from outside Chirpy.
+func (q *Queries) GetUserByEmailFold(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmailFold, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
 	)
 	return i, err
 }
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2 AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	HashedPassword    string
+	ID                uuid.UUID
+	OldHashedPassword string
+}
+
+// Only replaces the hash that was checked, so that a password changed in the
+// meantime is not overwritten with the old one.
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.HashedPassword, arg.ID, arg.OldHashedPassword)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL
+`
+
+type SetUserTOTPSecretParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email = $2,
+    hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter
+`
+
+type UpdateUserParams struct {
+	ID             uuid.UUID
+	Email          string
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :one
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = NOW()
+WHERE id = $1 AND NOT is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, role, is_chirpy_red, email_verified_at, totp_secret, totp_enabled_at, totp_last_counter
+`
+
+func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, upgradeUserToChirpyRed, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const useUserTOTPCounter = `-- name: UseUserTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = $2, updated_at = NOW()
+WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2)
+`
+
+type UseUserTOTPCounterParams struct {
+	ID              uuid.UUID
+	TotpLastCounter sql.NullInt64
+}
+
+// Records the time step of a TOTP code that was just accepted. It affects no
+// rows if that step or a later one has been used before, so each code works
+// only once.
+func (q *Queries) UseUserTOTPCounter(ctx context.Context, arg UseUserTOTPCounterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserTOTPCounter, arg.ID, arg.TotpLastCounter)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
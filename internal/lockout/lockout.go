@@ -0,0 +1,80 @@
+// Package lockout throttles repeated failed attempts, such as wrong
+// passwords, per key. Keys are opaque strings chosen by the caller, for
+// example "account:alice@example.com" or "ip:203.0.113.7".
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Policy decides how long a key is blocked after a number of consecutive
+// failures.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before any delay.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts. It
+	// doubles with every further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// After LockoutThreshold failures the key is locked out for
+	// LockoutDuration.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// ResetAfter is how long after the last failure a key starts over.
+	ResetAfter time.Duration
+}
+
+var DefaultPolicy = Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         5 * time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       24 * time.Hour,
+}
+
+// Delay returns how long a key must wait after its last failure given its
+// number of consecutive failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// wait returns how long a key with the given failures is still blocked at
+// now.
+func (p Policy) wait(failures int, lastFailure, now time.Time) time.Duration {
+	if now.Sub(lastFailure) >= p.ResetAfter {
+		return 0
+	}
+	return max(lastFailure.Add(p.Delay(failures)).Sub(now), 0)
+}
+
+// Tracker records failed attempts. A zero duration from Check or Attempt
+// means the key may try again right away.
+type Tracker interface {
+	// Check returns how long key must wait before its next attempt.
+	Check(ctx context.Context, key string) (time.Duration, error)
+	// Attempt reserves an attempt for key. If key must still wait, nothing
+	// is recorded and the wait is returned. Otherwise the attempt is counted
+	// as a failure before it is made, so that concurrent attempts cannot all
+	// slip through, and zero is returned.
+	Attempt(ctx context.Context, key string) (time.Duration, error)
+	// Release takes back an attempt that turned out not to be a failure.
+	Release(ctx context.Context, key string) error
+	// Reset forgets all failures of key.
+	Reset(ctx context.Context, key string) error
+}
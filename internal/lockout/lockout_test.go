@@ -0,0 +1,181 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         10 * time.Second,
+	LockoutThreshold: 8,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       24 * time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	expected := []time.Duration{
+		0,
+		0,
+		0,
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		time.Hour,
+		time.Hour,
+	}
+
+	for failures, want := range expected {
+		if got := testPolicy.Delay(failures); got != want {
+			t.Errorf("expected delay %v after %d failures, got %v", want, failures, got)
+		}
+	}
+}
+
+func TestMemoryTracker(t *testing.T) {
+	ctx := context.Background()
+
+	newTracker := func(now *time.Time) *MemoryTracker {
+		tracker := NewMemoryTracker(testPolicy)
+		tracker.now = func() time.Time { return *now }
+		return tracker
+	}
+
+	// fail makes n failed attempts, waiting out any backoff in between.
+	fail := func(tracker *MemoryTracker, now *time.Time, n int) {
+		for range n {
+			wait, _ := tracker.Check(ctx, "key")
+			*now = now.Add(wait)
+			tracker.Attempt(ctx, "key")
+		}
+	}
+
+	t.Run("allows free attempts", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		for range testPolicy.FreeAttempts {
+			if wait, _ := tracker.Attempt(ctx, "key"); wait != 0 {
+				t.Fatalf("expected no wait, got %v", wait)
+			}
+		}
+
+		if wait, _ := tracker.Check(ctx, "key"); wait != 0 {
+			t.Errorf("expected no wait, got %v", wait)
+		}
+	})
+
+	t.Run("backs off after free attempts", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.FreeAttempts+2)
+
+		if wait, _ := tracker.Check(ctx, "key"); wait != 2*time.Second {
+			t.Errorf("expected wait of 2s, got %v", wait)
+		}
+
+		now = now.Add(2 * time.Second)
+		if wait, _ := tracker.Check(ctx, "key"); wait != 0 {
+			t.Errorf("expected no wait after backoff, got %v", wait)
+		}
+	})
+
+	t.Run("locks out after threshold", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.LockoutThreshold)
+
+		if wait, _ := tracker.Check(ctx, "key"); wait != time.Hour {
+			t.Errorf("expected lockout of 1h, got %v", wait)
+		}
+	})
+
+	t.Run("keeps keys separate", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.LockoutThreshold)
+
+		if wait, _ := tracker.Check(ctx, "other"); wait != 0 {
+			t.Errorf("expected no wait for other key, got %v", wait)
+		}
+	})
+
+	t.Run("reset unlocks", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.LockoutThreshold)
+		tracker.Reset(ctx, "key")
+
+		if wait, _ := tracker.Check(ctx, "key"); wait != 0 {
+			t.Errorf("expected no wait after reset, got %v", wait)
+		}
+	})
+
+	t.Run("forgets old failures", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.LockoutThreshold-1)
+
+		now = now.Add(testPolicy.ResetAfter)
+		if wait, _ := tracker.Attempt(ctx, "key"); wait != 0 {
+			t.Errorf("expected failures to start over, got wait of %v", wait)
+		}
+	})
+
+	t.Run("does not count blocked attempts", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.FreeAttempts+1)
+
+		for range 3 {
+			if wait, _ := tracker.Attempt(ctx, "key"); wait != time.Second {
+				t.Fatalf("expected wait of 1s, got %v", wait)
+			}
+		}
+	})
+
+	t.Run("release takes back an attempt", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		fail(tracker, &now, testPolicy.FreeAttempts)
+		tracker.Attempt(ctx, "key")
+		tracker.Release(ctx, "key")
+
+		if wait, _ := tracker.Check(ctx, "key"); wait != 0 {
+			t.Errorf("expected no wait after release, got %v", wait)
+		}
+	})
+
+	t.Run("reserves concurrent attempts", func(t *testing.T) {
+		now := time.Now()
+		tracker := newTracker(&now)
+
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for range 20 {
+			wg.Go(func() {
+				if wait, _ := tracker.Attempt(ctx, "key"); wait == 0 {
+					allowed.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+
+		if got := allowed.Load(); got != int32(testPolicy.FreeAttempts+1) {
+			t.Errorf("expected %d attempts to go through, got %d", testPolicy.FreeAttempts+1, got)
+		}
+	})
+}
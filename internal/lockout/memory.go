@@ -0,0 +1,97 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryTracker keeps failures in process memory. It is only suitable for a
+// single replica; use PostgresTracker when running more than one.
+type MemoryTracker struct {
+	policy Policy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	failures    int
+	lastFailure time.Time
+}
+
+func NewMemoryTracker(policy Policy) *MemoryTracker {
+	return &MemoryTracker{
+		policy:  policy,
+		now:     time.Now,
+		entries: map[string]memoryEntry{},
+	}
+}
+
+func (t *MemoryTracker) Check(ctx context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	return t.policy.wait(entry.failures, entry.lastFailure, t.now()), nil
+}
+
+func (t *MemoryTracker) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+
+	entry := t.entries[key]
+	if now.Sub(entry.lastFailure) >= t.policy.ResetAfter {
+		entry.failures = 0
+	}
+	if wait := t.policy.wait(entry.failures, entry.lastFailure, now); wait > 0 {
+		return wait, nil
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+	t.entries[key] = entry
+	return 0, nil
+}
+
+func (t *MemoryTracker) Release(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry, ok := t.entries[key]; ok && entry.failures > 0 {
+		entry.failures--
+		t.entries[key] = entry
+	}
+	return nil
+}
+
+func (t *MemoryTracker) Reset(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+	return nil
+}
+
+// sweep drops entries that have been quiet for longer than ResetAfter so
+// that the map does not grow without bound.
+func (t *MemoryTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) >= t.policy.ResetAfter {
+			delete(t.entries, key)
+		}
+	}
+}
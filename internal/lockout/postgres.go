@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/khizar-sudo/chirpy/internal/database"
+)
+
+// PostgresTracker keeps failures in the login_attempts table so that every
+// replica sees the same counts.
+type PostgresTracker struct {
+	policy Policy
+	db     *database.Queries
+	now    func() time.Time
+}
+
+func NewPostgresTracker(policy Policy, db *database.Queries) *PostgresTracker {
+	return &PostgresTracker{
+		policy: policy,
+		db:     db,
+		now:    time.Now,
+	}
+}
+
+func (t *PostgresTracker) Check(ctx context.Context, key string) (time.Duration, error) {
+	attempt, err := t.db.GetLoginAttempt(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return t.policy.wait(int(attempt.Failures), attempt.LastFailureAt, t.now().UTC()), nil
+}
+
+// Attempt reads the key's failures and writes the incremented count back
+// only if nobody else has changed them in the meantime, retrying otherwise.
+// This way the wait is decided and the attempt recorded as one step.
+func (t *PostgresTracker) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	for {
+		now := t.now().UTC()
+
+		attempt, err := t.db.GetLoginAttempt(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			inserted, err := t.db.InsertLoginAttempt(ctx, database.InsertLoginAttemptParams{
+				Key:           key,
+				LastFailureAt: now,
+			})
+			if err != nil {
+				return 0, err
+			}
+			if inserted == 1 {
+				return 0, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		failures := int(attempt.Failures)
+		if now.Sub(attempt.LastFailureAt) >= t.policy.ResetAfter {
+			failures = 0
+		}
+		if wait := t.policy.wait(failures, attempt.LastFailureAt, now); wait > 0 {
+			return wait, nil
+		}
+
+		updated, err := t.db.ReserveLoginAttempt(ctx, database.ReserveLoginAttemptParams{
+			Key:               key,
+			Failures:          int32(failures + 1),
+			LastFailureAt:     now,
+			PreviousFailures:  attempt.Failures,
+			PreviousFailureAt: attempt.LastFailureAt,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if updated == 1 {
+			return 0, nil
+		}
+	}
+}
+
+func (t *PostgresTracker) Release(ctx context.Context, key string) error {
+	return t.db.ReleaseLoginAttempt(ctx, key)
+}
+
+func (t *PostgresTracker) Reset(ctx context.Context, key string) error {
+	return t.db.DeleteLoginAttempt(ctx, key)
+}
@@ -0,0 +1,56 @@
+// Package mailer sends transactional email such as password reset links.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes messages to the standard logger instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer appends messages to a file instead of sending them, so flows
+// that depend on email can be exercised offline.
+type FileMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{path: path}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().UTC().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
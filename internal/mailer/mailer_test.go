@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	t.Run("appends messages to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mail.txt")
+		m := NewFileMailer(path)
+
+		for _, to := range []string{"a@example.com", "b@example.com"} {
+			err := m.Send(context.Background(), Message{To: to, Subject: "Hello", Body: "token: abc"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read mail file: %v", err)
+		}
+
+		content := string(data)
+		for _, want := range []string{"To: a@example.com", "To: b@example.com", "Subject: Hello", "token: abc"} {
+			if !strings.Contains(content, want) {
+				t.Errorf("expected mail file to contain %q, got %q", want, content)
+			}
+		}
+	})
+}
+
+// fakeSMTPServer accepts a single connection, plays along with the SMTP
+// commands a client sends and delivers the received message data on the
+// returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("sends message", func(t *testing.T) {
+		addr, received := fakeSMTPServer(t)
+		m, err := NewSMTPMailer(addr, "chirpy@example.com", "", "")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err = m.Send(context.Background(), Message{To: "a@example.com", Subject: "Hello", Body: "token: abc"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		data := <-received
+		for _, want := range []string{"From: chirpy@example.com", "To: a@example.com", "Subject: Hello", "token: abc"} {
+			if !strings.Contains(data, want) {
+				t.Errorf("expected message to contain %q, got %q", want, data)
+			}
+		}
+	})
+
+	t.Run("rejects line breaks in headers", func(t *testing.T) {
+		m, err := NewSMTPMailer("127.0.0.1:25", "chirpy@example.com", "", "")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err = m.Send(context.Background(), Message{To: "a@example.com", Subject: "Hello\r\nBcc: b@example.com"})
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("requires a sender", func(t *testing.T) {
+		if _, err := NewSMTPMailer("127.0.0.1:25", "", "", ""); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
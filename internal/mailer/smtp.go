@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP server. It upgrades the
+// connection with STARTTLS whenever the server offers it, and authenticates
+// if a username is set.
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer that sends from from through the server at
+// addr, given as host:port. username and password may be empty for servers
+// that do not need authentication.
+func NewSMTPMailer(addr, from, username, password string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address: %w", err)
+	}
+	if from == "" {
+		return nil, errors.New("sender address is required")
+	}
+
+	m := &SMTPMailer{addr: addr, host: host, from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	// Headers are written as given, so a line break would let the caller add
+	// headers or recipients of their own.
+	for _, header := range []string{msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return errors.New("header contains a line break")
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		m.from, msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,225 @@
+// Package oidc signs users in with an external OpenID Connect provider
+// using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway allows for clock skew between us and the provider.
+const leeway = time.Minute
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrNonceMismatch  = errors.New("nonce does not match")
+)
+
+// Config describes a provider registered with Chirpy.
+type Config struct {
+	// Name identifies the provider in our URLs and in user_identities.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested on top of openid.
+	Scopes []string
+	// HTTPClient is used to talk to the provider. It defaults to a client
+	// with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of a provider's discovery document that we use.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of an ID token that we use.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	AuthorizedParty string `json:"azp"`
+}
+
+// Provider is an OpenID Connect provider. Its discovery document is fetched
+// on first use, so that a provider that is down does not stop Chirpy from
+// starting.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     *keySet
+}
+
+func NewProvider(config Config) *Provider {
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: config, client: client}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Discover returns the provider's metadata, fetching it if needed.
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	metadata := &Metadata{}
+	if err := getJSON(ctx, p.client, issuer+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", p.config.Name, err)
+	}
+
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("discovering %s: issuer %q does not match %q", p.config.Name, metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("discovering %s: incomplete discovery document", p.config.Name)
+	}
+
+	p.metadata = metadata
+	p.keys = &keySet{uri: metadata.JWKSURI, client: p.client, now: time.Now}
+	return metadata, nil
+}
+
+// AuthCodeURL returns the URL to send the user to in order to sign in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.config.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims of
+// the ID token that came with it.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding token response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint: %s: %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, errors.New("token endpoint: no id_token in response")
+	}
+
+	return p.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to %s", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
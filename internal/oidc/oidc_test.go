@@ -0,0 +1,355 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is a minimal OpenID Connect provider for tests.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	kid   string
+	codes map[string]mockGrant
+	// issuer overrides the issuer in the discovery document.
+	issuer string
+	// claims are changed before signing ID tokens.
+	claims func(*Claims)
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+	subject   string
+	email     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	idp := &mockIdP{t: t, codes: map[string]mockGrant{}}
+	idp.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("GET /jwks", idp.jwks)
+	mux.HandleFunc("POST /token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatalf("failed to generate key: %v", err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.kid = kid
+}
+
+func (idp *mockIdP) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/mock/callback",
+		Scopes:       []string{"email"},
+		HTTPClient:   idp.server.Client(),
+	})
+}
+
+// authorize plays the part of the user signing in at the provider and
+// returns the code the provider would redirect back with.
+func (idp *mockIdP) authorize(authURL, subject, email string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatalf("failed to parse auth URL: %v", err)
+	}
+	q := u.Query()
+
+	code, err := RandomString()
+	if err != nil {
+		idp.t.Fatalf("failed to make code: %v", err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.codes[code] = mockGrant{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		subject:   subject,
+		email:     email,
+	}
+	return code
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := idp.issuer
+	if issuer == "" {
+		issuer = idp.server.URL
+	}
+	json.NewEncoder(w).Encode(Metadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: idp.server.URL + "/authorize",
+		TokenEndpoint:         idp.server.URL + "/token",
+		JWKSURI:               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []jwk{{
+			Kty: "RSA",
+			Kid: idp.kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	if id, secret, ok := r.BasicAuth(); !ok || id != "chirpy" || secret != "s3cret" {
+		tokenError("invalid_client")
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	if !ok || CodeChallenge(r.PostFormValue("code_verifier")) != grant.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     idp.signIDToken(grant),
+	})
+}
+
+func (idp *mockIdP) signIDToken(grant mockGrant) string {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.server.URL,
+			Subject:   grant.subject,
+			Audience:  jwt.ClaimStrings{"chirpy"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:         grant.nonce,
+		Email:         grant.email,
+		EmailVerified: true,
+	}
+	if idp.claims != nil {
+		idp.claims(&claims)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+// login runs the whole flow against idp and returns the result of Exchange.
+func login(t *testing.T, idp *mockIdP, p *Provider) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, _ := RandomString()
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("failed to make auth URL: %v", err)
+	}
+
+	code := idp.authorize(authURL, "user-123", "walt@breakingbad.com")
+	return p.Exchange(ctx, code, verifier, nonce)
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("builds the authorization URL", func(t *testing.T) {
+		idp := newMockIdP(t)
+
+		authURL, err := idp.provider().AuthCodeURL(ctx, "the-state", "the-nonce", "the-verifier")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatalf("failed to parse URL: %v", err)
+		}
+
+		want := map[string]string{
+			"response_type":         "code",
+			"client_id":             "chirpy",
+			"redirect_uri":          "http://localhost:8080/api/auth/oidc/mock/callback",
+			"scope":                 "openid email",
+			"state":                 "the-state",
+			"nonce":                 "the-nonce",
+			"code_challenge":        CodeChallenge("the-verifier"),
+			"code_challenge_method": "S256",
+		}
+		for param, value := range want {
+			if got := u.Query().Get(param); got != value {
+				t.Errorf("expected %s=%q, got %q", param, value, got)
+			}
+		}
+	})
+
+	t.Run("exchanges a code for verified claims", func(t *testing.T) {
+		idp := newMockIdP(t)
+
+		claims, err := login(t, idp, idp.provider())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if claims.Subject != "user-123" {
+			t.Errorf("expected subject user-123, got %s", claims.Subject)
+		}
+		if claims.Email != "walt@breakingbad.com" || !claims.EmailVerified {
+			t.Errorf("unexpected email claims %q %v", claims.Email, claims.EmailVerified)
+		}
+	})
+
+	t.Run("rejects the wrong PKCE verifier", func(t *testing.T) {
+		idp := newMockIdP(t)
+		p := idp.provider()
+
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		if err != nil {
+			t.Fatalf("failed to make auth URL: %v", err)
+		}
+		code := idp.authorize(authURL, "user-123", "walt@breakingbad.com")
+
+		if _, err := p.Exchange(ctx, code, "another-verifier", "nonce"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("rejects the wrong nonce", func(t *testing.T) {
+		idp := newMockIdP(t)
+		p := idp.provider()
+
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+		if err != nil {
+			t.Fatalf("failed to make auth URL: %v", err)
+		}
+		code := idp.authorize(authURL, "user-123", "walt@breakingbad.com")
+
+		if _, err := p.Exchange(ctx, code, "verifier", "another-nonce"); !errors.Is(err, ErrNonceMismatch) {
+			t.Fatalf("expected ErrNonceMismatch, got %v", err)
+		}
+	})
+
+	t.Run("rejects ID tokens for another client", func(t *testing.T) {
+		idp := newMockIdP(t)
+		idp.claims = func(c *Claims) { c.Audience = jwt.ClaimStrings{"someone-else"} }
+
+		if _, err := login(t, idp, idp.provider()); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects ID tokens shared with another client", func(t *testing.T) {
+		idp := newMockIdP(t)
+		idp.claims = func(c *Claims) {
+			c.Audience = jwt.ClaimStrings{"chirpy", "someone-else"}
+			c.AuthorizedParty = "someone-else"
+		}
+
+		if _, err := login(t, idp, idp.provider()); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects ID tokens from another issuer", func(t *testing.T) {
+		idp := newMockIdP(t)
+		idp.claims = func(c *Claims) { c.Issuer = "https://evil.example.com" }
+
+		if _, err := login(t, idp, idp.provider()); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects expired ID tokens", func(t *testing.T) {
+		idp := newMockIdP(t)
+		idp.claims = func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }
+
+		if _, err := login(t, idp, idp.provider()); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("picks up rotated signing keys", func(t *testing.T) {
+		idp := newMockIdP(t)
+		p := idp.provider()
+
+		if _, err := login(t, idp, p); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		idp.rotateKey("key-2")
+		// Unknown keys only cause a refetch once a while.
+		p.keys.now = func() time.Time { return time.Now().Add(minRefetchInterval) }
+
+		if _, err := login(t, idp, p); err != nil {
+			t.Fatalf("expected no error after key rotation, got %v", err)
+		}
+	})
+
+	t.Run("rejects a discovery document for another issuer", func(t *testing.T) {
+		idp := newMockIdP(t)
+		idp.issuer = "https://evil.example.com"
+
+		if _, err := idp.provider().Discover(ctx); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a listing ordered by (CreatedAt, ID). Scope ties
+// the cursor to the listing it came from so it cannot be replayed against a
+// different filter or sort order.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Scope     string    `json:"s"`
+}
+
+// Encode serializes the cursor into an opaque, URL-safe token signed with
+// HMAC-SHA256.
+func Encode(cursor Cursor, secret []byte) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(encoded, secret), nil
+}
+
+// Decode verifies the token signature and that it was issued for scope.
+func Decode(token, scope string, secret []byte) (Cursor, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(sign(encoded, secret))) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	cursor := Cursor{}
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if cursor.Scope != scope {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func sign(encoded string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
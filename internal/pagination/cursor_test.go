@@ -0,0 +1,88 @@
+package pagination
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursor(t *testing.T) {
+	secret := []byte("test-secret")
+
+	t.Run("round trips a cursor", func(t *testing.T) {
+		cursor := Cursor{
+			CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 678000, time.UTC),
+			ID:        uuid.New(),
+			Scope:     "chirps:asc",
+		}
+
+		token, err := Encode(cursor, secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		decoded, err := Decode(token, "chirps:asc", secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+			t.Errorf("expected %+v, got %+v", cursor, decoded)
+		}
+	})
+
+	t.Run("rejects a tampered cursor", func(t *testing.T) {
+		token, err := Encode(Cursor{ID: uuid.New(), Scope: "chirps:asc"}, secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		other, err := Encode(Cursor{ID: uuid.New(), Scope: "chirps:asc"}, secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		payload, _, _ := strings.Cut(other, ".")
+		_, signature, _ := strings.Cut(token, ".")
+
+		_, err = Decode(payload+"."+signature, "chirps:asc", secret)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("rejects a cursor signed with another secret", func(t *testing.T) {
+		token, err := Encode(Cursor{ID: uuid.New(), Scope: "chirps:asc"}, []byte("other-secret"))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		_, err = Decode(token, "chirps:asc", secret)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("rejects a cursor from another scope", func(t *testing.T) {
+		token, err := Encode(Cursor{ID: uuid.New(), Scope: "chirps:asc"}, secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		_, err = Decode(token, "chirps:desc", secret)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("rejects garbage", func(t *testing.T) {
+		for _, token := range []string{"", "abc", "abc.def", "."} {
+			if _, err := Decode(token, "chirps:asc", secret); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor for %q, got %v", token, err)
+			}
+		}
+	})
+}
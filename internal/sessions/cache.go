@@ -0,0 +1,97 @@
+// Package sessions caches whether login sessions have been revoked so that
+// access tokens can be checked against their session without a database
+// round trip on every request.
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// revokedTTL is how long a revoked session is remembered. Revocation is
+// permanent, so this only needs to outlive the access tokens of the session.
+const revokedTTL = time.Hour
+
+// Lookup reports whether a session has been revoked. Sessions that do not
+// exist should be reported as revoked.
+type Lookup func(ctx context.Context, id uuid.UUID) (revoked bool, err error)
+
+// RevocationCache remembers the answers of a Lookup for a while. A session
+// revoked by another replica is noticed within the TTL; sessions revoked
+// through MarkRevoked are rejected straight away.
+type RevocationCache struct {
+	ttl    time.Duration
+	lookup Lookup
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[uuid.UUID]cacheEntry
+	lastSweep time.Time
+}
+
+type cacheEntry struct {
+	revoked bool
+	expires time.Time
+}
+
+func NewRevocationCache(ttl time.Duration, lookup Lookup) *RevocationCache {
+	return &RevocationCache{
+		ttl:     ttl,
+		lookup:  lookup,
+		now:     time.Now,
+		entries: map[uuid.UUID]cacheEntry{},
+	}
+}
+
+func (c *RevocationCache) Revoked(ctx context.Context, id uuid.UUID) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	now := c.now()
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.lookup(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := c.ttl
+	if revoked {
+		ttl = revokedTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep(now)
+	c.entries[id] = cacheEntry{revoked: revoked, expires: now.Add(ttl)}
+
+	return revoked, nil
+}
+
+// MarkRevoked records that a session was just revoked.
+func (c *RevocationCache) MarkRevoked(id uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[id] = cacheEntry{revoked: true, expires: c.now().Add(revokedTTL)}
+}
+
+// sweep drops expired entries so that the map does not grow without bound.
+func (c *RevocationCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+
+	for id, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeLookup struct {
+	revoked map[uuid.UUID]bool
+	err     error
+	calls   int
+}
+
+func (f *fakeLookup) lookup(ctx context.Context, id uuid.UUID) (bool, error) {
+	f.calls++
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.revoked[id], nil
+}
+
+func TestRevocationCache(t *testing.T) {
+	ctx := context.Background()
+
+	newCache := func() (*RevocationCache, *fakeLookup, *time.Time) {
+		fake := &fakeLookup{revoked: map[uuid.UUID]bool{}}
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		cache := NewRevocationCache(30*time.Second, fake.lookup)
+		cache.now = func() time.Time { return now }
+		return cache, fake, &now
+	}
+
+	t.Run("caches lookups until the TTL passes", func(t *testing.T) {
+		cache, fake, now := newCache()
+		id := uuid.New()
+
+		for range 3 {
+			revoked, err := cache.Revoked(ctx, id)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if revoked {
+				t.Fatal("expected session not to be revoked")
+			}
+		}
+		if fake.calls != 1 {
+			t.Errorf("expected 1 lookup, got %d", fake.calls)
+		}
+
+		fake.revoked[id] = true
+		*now = now.Add(31 * time.Second)
+
+		revoked, err := cache.Revoked(ctx, id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !revoked {
+			t.Error("expected revocation to be noticed after the TTL")
+		}
+		if fake.calls != 2 {
+			t.Errorf("expected 2 lookups, got %d", fake.calls)
+		}
+	})
+
+	t.Run("sessions marked as revoked are rejected straight away", func(t *testing.T) {
+		cache, fake, _ := newCache()
+		id := uuid.New()
+
+		if _, err := cache.Revoked(ctx, id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		cache.MarkRevoked(id)
+
+		revoked, err := cache.Revoked(ctx, id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !revoked {
+			t.Error("expected session to be revoked")
+		}
+		if fake.calls != 1 {
+			t.Errorf("expected 1 lookup, got %d", fake.calls)
+		}
+	})
+
+	t.Run("revoked sessions are remembered longer than the TTL", func(t *testing.T) {
+		cache, fake, now := newCache()
+		id := uuid.New()
+		fake.revoked[id] = true
+
+		if _, err := cache.Revoked(ctx, id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		*now = now.Add(10 * time.Minute)
+		if _, err := cache.Revoked(ctx, id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if fake.calls != 1 {
+			t.Errorf("expected 1 lookup, got %d", fake.calls)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		cache, fake, _ := newCache()
+		id := uuid.New()
+		fake.err = errors.New("database is down")
+
+		if _, err := cache.Revoked(ctx, id); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		fake.err = nil
+		revoked, err := cache.Revoked(ctx, id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if revoked {
+			t.Error("expected session not to be revoked")
+		}
+		if fake.calls != 2 {
+			t.Errorf("expected 2 lookups, got %d", fake.calls)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+// Package timeline builds home timelines: the chirps of the users someone
+// follows along with their own, newest first.
+//
+// A timeline can be assembled when it is read, by joining follows with
+// chirps, or kept up to date as chirps are posted, in which case reading it
+// is a single index scan. The first is cheap for users who follow few
+// accounts; the second pays off for users who follow many. Hybrid picks
+// between them per user.
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/pagination"
+)
+
+// Strategy reads timelines and is told about the events that change them.
+// The events write through q, which callers bind to the transaction that
+// made the change so that timelines never drift from chirps and follows.
+type Strategy interface {
+	// Page returns up to limit chirps of userID's timeline, newest first,
+	// starting after the chirp marked by after if it is not nil.
+	Page(ctx context.Context, userID uuid.UUID, after *pagination.Cursor, limit int32) ([]database.Chirp, error)
+	// Posted is called after a chirp has been created.
+	Posted(ctx context.Context, q *database.Queries, chirp database.Chirp) error
+	// Followed is called after followerID has started following followeeID.
+	Followed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error
+	// Unfollowed is called after followerID has stopped following
+	// followeeID.
+	Unfollowed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error
+}
+
+func cursorParams(after *pagination.Cursor) (sql.NullTime, uuid.NullUUID) {
+	if after == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: after.CreatedAt, Valid: true}, uuid.NullUUID{UUID: after.ID, Valid: true}
+}
+
+// FanOutOnRead assembles timelines when they are read. It keeps no state of
+// its own.
+type FanOutOnRead struct {
+	db *database.Queries
+}
+
+func NewFanOutOnRead(db *database.Queries) *FanOutOnRead {
+	return &FanOutOnRead{db: db}
+}
+
+func (s *FanOutOnRead) Page(ctx context.Context, userID uuid.UUID, after *pagination.Cursor, limit int32) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := cursorParams(after)
+	return s.db.ListTimelineChirps(ctx, database.ListTimelineChirpsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           limit,
+	})
+}
+
+func (s *FanOutOnRead) Posted(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	return nil
+}
+
+func (s *FanOutOnRead) Followed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return nil
+}
+
+func (s *FanOutOnRead) Unfollowed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return nil
+}
+
+// BackfillLimit is how many chirps FanOutOnWrite copies into a timeline when
+// it is materialized, and how many of an account's chirps it adds when the
+// user follows them, so that neither costs more than a bounded amount of
+// work. Older chirps are left out of the materialized timeline and read the
+// way FanOutOnRead reads them instead.
+const BackfillLimit = 1000
+
+// FanOutOnWrite keeps timelines in the timeline_entries table. A user's
+// timeline is materialized the first time it is read and maintained from
+// then on.
+type FanOutOnWrite struct {
+	sqlDB *sql.DB
+	db    *database.Queries
+	read  *FanOutOnRead
+}
+
+func NewFanOutOnWrite(db *sql.DB) *FanOutOnWrite {
+	queries := database.New(db)
+	return &FanOutOnWrite{
+		sqlDB: db,
+		db:    queries,
+		read:  NewFanOutOnRead(queries),
+	}
+}
+
+func (s *FanOutOnWrite) Page(ctx context.Context, userID uuid.UUID, after *pagination.Cursor, limit int32) ([]database.Chirp, error) {
+	timeline, err := s.db.GetMaterializedTimeline(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := s.Materialize(ctx, userID); err != nil {
+			return nil, err
+		}
+		timeline, err = s.db.GetMaterializedTimeline(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cursorCreatedAt, cursorID := cursorParams(after)
+	chirps, err := s.db.ListMaterializedTimeline(ctx, database.ListMaterializedTimelineParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           limit,
+	})
+	if err != nil || !timeline.CompleteAfter.Valid {
+		return chirps, err
+	}
+
+	// Past the point where the backfills stopped, the entries are missing
+	// chirps, so the rest of the page is read from follows.
+	n := 0
+	for n < len(chirps) && chirps[n].CreatedAt.After(timeline.CompleteAfter.Time) {
+		n++
+	}
+	chirps = chirps[:n]
+	if n == int(limit) {
+		return chirps, nil
+	}
+	if n > 0 {
+		last := chirps[n-1]
+		after = &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	older, err := s.read.Page(ctx, userID, after, limit-int32(n))
+	if err != nil {
+		return nil, err
+	}
+	return append(chirps, older...), nil
+}
+
+// Materialize starts keeping userID's timeline, filled with its newest
+// BackfillLimit chirps. It does nothing if the timeline is already kept.
+//
+// Posting, following and unfollowing only update timelines that are already
+// materialized, so it first waits for those still in progress that affect
+// userID. Otherwise a chirp posted while the timeline is being filled could
+// be missed by both.
+func (s *FanOutOnWrite) Materialize(ctx context.Context, userID uuid.UUID) error {
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := s.db.WithTx(tx)
+	if err := q.LockTimelineFollows(ctx, userID); err != nil {
+		return err
+	}
+	if err := q.LockTimelineAuthors(ctx, userID); err != nil {
+		return err
+	}
+
+	err = q.MaterializeTimeline(ctx, database.MaterializeTimelineParams{
+		UserID: userID,
+		Limit:  BackfillLimit,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *FanOutOnWrite) Posted(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	return q.FanOutChirp(ctx, database.FanOutChirpParams{
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		AuthorID:  chirp.UserID,
+	})
+}
+
+func (s *FanOutOnWrite) Followed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return q.AddFolloweeToTimeline(ctx, database.AddFolloweeToTimelineParams{
+		FolloweeID: followeeID,
+		Limit:      BackfillLimit,
+		FollowerID: followerID,
+	})
+}
+
+func (s *FanOutOnWrite) Unfollowed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return q.RemoveFolloweeFromTimeline(ctx, database.RemoveFolloweeFromTimelineParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+}
+
+// Hybrid reads the timelines of users who follow at least Threshold
+// accounts with FanOutOnWrite and everyone else's with FanOutOnRead. Once a
+// timeline is materialized it stays that way, even if the user later
+// follows fewer accounts, so users near the threshold do not flip between
+// strategies.
+type Hybrid struct {
+	Threshold int64
+
+	db    *database.Queries
+	read  *FanOutOnRead
+	write *FanOutOnWrite
+}
+
+func NewHybrid(db *sql.DB, threshold int64) *Hybrid {
+	queries := database.New(db)
+	return &Hybrid{
+		Threshold: threshold,
+		db:        queries,
+		read:      NewFanOutOnRead(queries),
+		write:     NewFanOutOnWrite(db),
+	}
+}
+
+func (s *Hybrid) Page(ctx context.Context, userID uuid.UUID, after *pagination.Cursor, limit int32) ([]database.Chirp, error) {
+	materialized, err := s.db.IsTimelineMaterialized(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if materialized {
+		return s.write.Page(ctx, userID, after, limit)
+	}
+
+	counts, err := s.db.GetFollowCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if counts.Following >= s.Threshold {
+		return s.write.Page(ctx, userID, after, limit)
+	}
+
+	return s.read.Page(ctx, userID, after, limit)
+}
+
+// The events only touch materialized timelines, so Hybrid passes them all on
+// to FanOutOnWrite.
+
+func (s *Hybrid) Posted(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	return s.write.Posted(ctx, q, chirp)
+}
+
+func (s *Hybrid) Followed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return s.write.Followed(ctx, q, followerID, followeeID)
+}
+
+func (s *Hybrid) Unfollowed(ctx context.Context, q *database.Queries, followerID, followeeID uuid.UUID) error {
+	return s.write.Unfollowed(ctx, q, followerID, followeeID)
+}
@@ -0,0 +1,379 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/khizar-sudo/chirpy/internal/database"
+	"github.com/khizar-sudo/chirpy/internal/pagination"
+	_ "github.com/lib/pq"
+)
+
+// The tests and benchmarks need a migrated database in TEST_DB_URL. They
+// seed their own users under a unique email prefix and delete them when
+// done.
+
+const (
+	benchAuthors         = 1000
+	benchChirpsPerAuthor = 20
+	benchPageSize        = 20
+)
+
+func openTestDB(tb testing.TB) *sql.DB {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		tb.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		tb.Fatalf("expected no error, got %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seedPrefix returns a unique email prefix for seeded users and deletes them
+// once the test is done.
+func seedPrefix(tb testing.TB, db *sql.DB) string {
+	prefix := "timeline-test-" + uuid.NewString() + "-"
+	tb.Cleanup(func() {
+		if _, err := db.Exec(`DELETE FROM users WHERE email LIKE $1`, prefix+"%"); err != nil {
+			tb.Errorf("could not delete seeded users: %v", err)
+		}
+	})
+	return prefix
+}
+
+func seedUser(tb testing.TB, db *sql.DB, email string) uuid.UUID {
+	var id uuid.UUID
+	err := db.QueryRow(`
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, 'unset')
+RETURNING id`, email).Scan(&id)
+	if err != nil {
+		tb.Fatalf("expected no error, got %v", err)
+	}
+	return id
+}
+
+// seedAuthors creates benchAuthors users with benchChirpsPerAuthor chirps
+// each, spread over the last 30 days, and returns the email prefix they
+// share.
+func seedAuthors(b *testing.B, db *sql.DB) string {
+	prefix := seedPrefix(b, db)
+
+	_, err := db.Exec(`
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+SELECT gen_random_uuid(), NOW(), NOW(), $1 || 'author-' || LPAD(i::text, 6, '0') || '@example.com', 'unset'
+FROM generate_series(1, $2::int) AS i`, prefix, benchAuthors)
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = db.Exec(`
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+SELECT gen_random_uuid(), NOW() - random() * INTERVAL '30 days', NOW(), 'Benchmark chirp', users.id
+FROM users, generate_series(1, $2::int)
+WHERE users.email LIKE $1`, prefix+"author-%", benchChirpsPerAuthor)
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+
+	return prefix
+}
+
+// seedReader creates a user following the first n seeded authors.
+func seedReader(b *testing.B, db *sql.DB, prefix string, n int) uuid.UUID {
+	readerID := seedUser(b, db, fmt.Sprintf("%sreader-%d@example.com", prefix, n))
+
+	_, err := db.Exec(`
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT $1, id, NOW() FROM users
+WHERE email LIKE $2
+ORDER BY email
+LIMIT $3`, readerID, prefix+"author-%", n)
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+
+	return readerID
+}
+
+// TestStrategiesAgree gives each strategy a reader of its own with the same
+// follows and checks that they all page through the same timeline as those
+// follows change and new chirps are posted.
+func TestStrategiesAgree(t *testing.T) {
+	db := openTestDB(t)
+	queries := database.New(db)
+	prefix := seedPrefix(t, db)
+	ctx := context.Background()
+
+	authors := make([]uuid.UUID, 4)
+	for i := range authors {
+		authors[i] = seedUser(t, db, fmt.Sprintf("%sauthor-%d@example.com", prefix, i))
+		_, err := db.Exec(`
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+SELECT gen_random_uuid(), NOW() - i * INTERVAL '1 minute', NOW(), 'Test chirp', $1
+FROM generate_series(1, 5) AS i`, authors[i])
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	readers := []struct {
+		name     string
+		strategy Strategy
+		id       uuid.UUID
+	}{
+		{name: "read", strategy: NewFanOutOnRead(queries)},
+		{name: "write", strategy: NewFanOutOnWrite(db)},
+		{name: "hybrid below threshold", strategy: NewHybrid(db, 100)},
+		{name: "hybrid above threshold", strategy: NewHybrid(db, 0)},
+	}
+	for i := range readers {
+		readers[i].id = seedUser(t, db, fmt.Sprintf("%sreader-%d@example.com", prefix, i))
+	}
+
+	follow := func(followeeID uuid.UUID) {
+		t.Helper()
+		for _, r := range readers {
+			if _, err := queries.CreateFollow(ctx, database.CreateFollowParams{FollowerID: r.id, FolloweeID: followeeID}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := r.strategy.Followed(ctx, queries, r.id, followeeID); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	}
+	unfollow := func(followeeID uuid.UUID) {
+		t.Helper()
+		for _, r := range readers {
+			if _, err := queries.DeleteFollow(ctx, database.DeleteFollowParams{FollowerID: r.id, FolloweeID: followeeID}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := r.strategy.Unfollowed(ctx, queries, r.id, followeeID); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	}
+	post := func(authorID uuid.UUID) {
+		t.Helper()
+		chirp, err := queries.CreateChirp(ctx, database.CreateChirpParams{Body: "New chirp", UserID: authorID})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, r := range readers {
+			if err := r.strategy.Posted(ctx, queries, chirp); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	}
+	// readAll pages through a reader's timeline three chirps at a time.
+	readAll := func(strategy Strategy, readerID uuid.UUID) []uuid.UUID {
+		t.Helper()
+		var ids []uuid.UUID
+		var after *pagination.Cursor
+		for {
+			chirps, err := strategy.Page(ctx, readerID, after, 3)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			for _, chirp := range chirps {
+				ids = append(ids, chirp.ID)
+			}
+			if len(chirps) < 3 {
+				return ids
+			}
+			last := chirps[len(chirps)-1]
+			after = &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+	}
+	check := func(step string, want int) {
+		t.Helper()
+		expected := readAll(readers[0].strategy, readers[0].id)
+		if len(expected) != want {
+			t.Fatalf("%s: expected %d chirps, got %d", step, want, len(expected))
+		}
+		for _, r := range readers[1:] {
+			got := readAll(r.strategy, r.id)
+			if !slices.Equal(got, expected) {
+				t.Errorf("%s: %s returned %v, expected %v", step, r.name, got, expected)
+			}
+		}
+	}
+
+	check("no follows", 0)
+
+	follow(authors[0])
+	follow(authors[1])
+	check("after following", 10)
+
+	post(authors[0])
+	post(authors[3])
+	check("after posting", 11)
+
+	follow(authors[2])
+	check("after following with a materialized timeline", 16)
+
+	unfollow(authors[1])
+	check("after unfollowing", 11)
+
+	post(authors[1])
+	post(authors[2])
+	check("after posting once unfollowed", 12)
+}
+
+// TestFanOutOnWritePastBackfillLimit checks that a materialized timeline
+// still pages through chirps older than its backfills reached.
+func TestFanOutOnWritePastBackfillLimit(t *testing.T) {
+	db := openTestDB(t)
+	queries := database.New(db)
+	prefix := seedPrefix(t, db)
+	ctx := context.Background()
+
+	// The authors' chirps interleave so that each backfill leaves out chirps
+	// the other one covers.
+	seedAuthor := func(name string, offset int) uuid.UUID {
+		t.Helper()
+		id := seedUser(t, db, prefix+name+"@example.com")
+		_, err := db.Exec(`
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+SELECT gen_random_uuid(), NOW() - (2 * i + $2) * INTERVAL '1 second', NOW(), 'Test chirp', $1
+FROM generate_series(1, $3::int) AS i`, id, offset, BackfillLimit+10)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return id
+	}
+	first := seedAuthor("first", 0)
+	second := seedAuthor("second", 1)
+
+	readAll := func(strategy Strategy, readerID uuid.UUID) []uuid.UUID {
+		t.Helper()
+		var ids []uuid.UUID
+		var after *pagination.Cursor
+		for {
+			chirps, err := strategy.Page(ctx, readerID, after, 100)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			for _, chirp := range chirps {
+				ids = append(ids, chirp.ID)
+			}
+			if len(chirps) < 100 {
+				return ids
+			}
+			last := chirps[len(chirps)-1]
+			after = &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+	}
+
+	read := NewFanOutOnRead(queries)
+	write := NewFanOutOnWrite(db)
+	readerID := seedUser(t, db, prefix+"reader@example.com")
+
+	for _, followeeID := range []uuid.UUID{first, second} {
+		if _, err := queries.CreateFollow(ctx, database.CreateFollowParams{FollowerID: readerID, FolloweeID: followeeID}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := write.Followed(ctx, queries, readerID, followeeID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := readAll(read, readerID)
+		got := readAll(write, readerID)
+		if !slices.Equal(got, expected) {
+			t.Errorf("expected %d chirps in the same order, got %d", len(expected), len(got))
+		}
+	}
+}
+
+func BenchmarkPage(b *testing.B) {
+	db := openTestDB(b)
+	queries := database.New(db)
+	prefix := seedAuthors(b, db)
+	ctx := context.Background()
+
+	for _, following := range []int{10, 100, benchAuthors} {
+		readerID := seedReader(b, db, prefix, following)
+
+		write := NewFanOutOnWrite(db)
+		if err := write.Materialize(ctx, readerID); err != nil {
+			b.Fatalf("expected no error, got %v", err)
+		}
+
+		strategies := []struct {
+			name     string
+			strategy Strategy
+		}{
+			{"read", NewFanOutOnRead(queries)},
+			{"write", write},
+		}
+		for _, s := range strategies {
+			b.Run(fmt.Sprintf("%s/following=%d", s.name, following), func(b *testing.B) {
+				for b.Loop() {
+					chirps, err := s.strategy.Page(ctx, readerID, nil, benchPageSize)
+					if err != nil {
+						b.Fatalf("expected no error, got %v", err)
+					}
+					if len(chirps) != benchPageSize {
+						b.Fatalf("expected %d chirps, got %d", benchPageSize, len(chirps))
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkPosted measures what posting costs an author whose followers all
+// have materialized timelines.
+func BenchmarkPosted(b *testing.B) {
+	db := openTestDB(b)
+	queries := database.New(db)
+	prefix := seedAuthors(b, db)
+	ctx := context.Background()
+
+	// Every seeded author follows the first one and reads with
+	// FanOutOnWrite.
+	var authorID uuid.UUID
+	err := db.QueryRow(`SELECT id FROM users WHERE email LIKE $1 ORDER BY email LIMIT 1`, prefix+"author-%").Scan(&authorID)
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+	_, err = db.Exec(`
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT id, $1, NOW() FROM users
+WHERE email LIKE $2 AND id <> $1`, authorID, prefix+"author-%")
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+	_, err = db.Exec(`
+INSERT INTO materialized_timelines (user_id, created_at)
+SELECT id, NOW() FROM users
+WHERE email LIKE $1`, prefix+"author-%")
+	if err != nil {
+		b.Fatalf("expected no error, got %v", err)
+	}
+
+	write := NewFanOutOnWrite(db)
+	b.Run(fmt.Sprintf("write/followers=%d", benchAuthors-1), func(b *testing.B) {
+		for b.Loop() {
+			chirp, err := queries.CreateChirp(ctx, database.CreateChirpParams{
+				Body:   "Benchmark chirp",
+				UserID: authorID,
+			})
+			if err != nil {
+				b.Fatalf("expected no error, got %v", err)
+			}
+			if err := write.Posted(ctx, queries, chirp); err != nil {
+				b.Fatalf("expected no error, got %v", err)
+			}
+		}
+	})
+}
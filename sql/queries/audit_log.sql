@@ -0,0 +1,10 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO audit_log (id, created_at, actor_id, action, target_id, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+);
\ No newline at end of file
@@ -1,18 +1,112 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, root_id, quote_of_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: CreateRepost :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, repost_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
     $2
 )
+ON CONFLICT (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL DO NOTHING
 RETURNING *;
 
 -- name: GetAllChrips :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
 -- name: GetChrip :one
 SELECT * FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetChirpsByIDs :many
+-- Looks up shared chirps to inline. Tombstones are left out so that a
+-- deleted chirp does not show up with its author and likes.
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]) AND deleted_at IS NULL;
+
+-- name: GetChirpThread :many
+-- Walks the conversation down from its root. path orders the chirps depth
+-- first with siblings oldest first; the timestamp is fixed width so the
+-- text sorts the same way the times do.
+WITH RECURSIVE thread AS (
+    SELECT chirps.*, 0 AS depth,
+           ARRAY[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] AS path
+    FROM chirps
+    WHERE chirps.id = sqlc.arg('root_id')
+    UNION ALL
+    SELECT chirps.*, thread.depth + 1,
+           thread.path || (to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text)
+    FROM chirps
+    JOIN thread ON chirps.parent_id = thread.id
+    WHERE thread.depth < sqlc.arg('max_depth')::int
+)
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, deleted_at, repost_of_id, quote_of_id, depth FROM thread
+ORDER BY path;
+
+-- name: LockChirp :exec
+-- Holds a chirp until the end of the transaction. New replies to it wait
+-- for the lock, so they cannot slip in while it is being deleted.
+SELECT id FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = $1;
+
+-- name: TombstoneChirp :execrows
+-- Chirps with replies are blanked out instead of deleted so their thread
+-- stays in one piece.
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = $1);
+
+-- name: PruneTombstone :one
+-- Deletes a tombstone once its last reply is gone.
+DELETE FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = $1)
+RETURNING parent_id;
+
+-- name: DeleteRepost :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND repost_of_id = $2;
+
+-- name: DeleteReposts :exec
+DELETE FROM chirps
+WHERE repost_of_id = $1;
\ No newline at end of file
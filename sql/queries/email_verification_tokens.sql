@@ -0,0 +1,24 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, created_at, user_id, email, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+);
+
+-- name: GetLatestEmailVerificationToken :one
+SELECT * FROM email_verification_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: UseEmailVerificationToken :one
+DELETE FROM email_verification_tokens
+WHERE token_hash = $1 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteUserEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens
+WHERE user_id = $1;
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowCounts :one
+SELECT
+    (SELECT COUNT(*) FROM follows WHERE followee_id = sqlc.arg('user_id')) AS followers,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = sqlc.arg('user_id')) AS following;
+
+-- name: ListFollowers :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE followee_id = sqlc.arg('user_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, follower_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListFollowing :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE follower_id = sqlc.arg('user_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, followee_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('limit');
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- name: LikeChirp :exec
+INSERT INTO likes (chirp_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM likes
+WHERE chirp_id = $1 AND user_id = $2;
+
+-- name: GetChirpLikeStats :many
+-- Counts are taken from the likes themselves rather than kept on chirps, so
+-- concurrent likes can never leave them wrong.
+SELECT chirp_id,
+       COUNT(*) AS like_count,
+       COALESCE(BOOL_OR(user_id = sqlc.narg('user_id')::uuid), FALSE)::boolean AS liked_by_me
+FROM likes
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id;
+
+-- name: ListChirpLikes :many
+SELECT user_id, created_at FROM likes
+WHERE chirp_id = sqlc.arg('chirp_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, user_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('limit');
\ No newline at end of file
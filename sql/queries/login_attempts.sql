@@ -0,0 +1,25 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1;
+
+-- name: InsertLoginAttempt :execrows
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES ($1, 1, $2)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: ReserveLoginAttempt :execrows
+-- Only matches if the failures have not changed since they were read.
+UPDATE login_attempts
+SET failures = sqlc.arg('failures'), last_failure_at = sqlc.arg('last_failure_at')
+WHERE key = sqlc.arg('key')
+    AND failures = sqlc.arg('previous_failures')
+    AND last_failure_at = sqlc.arg('previous_failure_at');
+
+-- name: ReleaseLoginAttempt :exec
+UPDATE login_attempts
+SET failures = GREATEST(failures - 1, 0)
+WHERE key = $1;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts
+WHERE key = $1;
\ No newline at end of file
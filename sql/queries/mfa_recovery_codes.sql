@@ -0,0 +1,17 @@
+-- name: CreateMFARecoveryCode :exec
+INSERT INTO mfa_recovery_codes (id, created_at, user_id, code_hash)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+);
+
+-- name: UseMFARecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteUserMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
\ No newline at end of file
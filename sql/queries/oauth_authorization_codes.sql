@@ -0,0 +1,17 @@
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: UseOAuthAuthorizationCode :one
+DELETE FROM oauth_authorization_codes
+WHERE code_hash = $1 AND expires_at > NOW()
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListUserOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1 AND owner_id = $2;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state_hash, created_at, provider, nonce, code_verifier, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: UseOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at <= NOW();
\ No newline at end of file
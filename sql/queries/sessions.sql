@@ -0,0 +1,61 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, last_used_at, user_id, user_agent, ip, client_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1;
+
+-- name: ListUserSessions :many
+-- Lists the sessions a user is still signed in with: login sessions with a
+-- refresh token that is neither used up nor expired, and app sessions whose
+-- access token has not expired yet.
+SELECT * FROM sessions
+WHERE user_id = sqlc.arg('user_id') AND revoked_at IS NULL
+  AND (EXISTS (SELECT 1 FROM refresh_tokens
+               WHERE refresh_tokens.family_id = sessions.id
+                 AND refresh_tokens.revoked_at IS NULL
+                 AND refresh_tokens.expires_at > NOW())
+       OR (client_id IS NOT NULL
+           AND created_at > NOW() - sqlc.arg('client_token_seconds')::int * INTERVAL '1 second'))
+ORDER BY last_used_at DESC;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = NOW(), ip = $2
+WHERE id = $1;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeClientSessions :many
+-- Ends every session users gave the OAuth client $1, as long as it belongs to
+-- $2.
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE client_id = (SELECT oauth_clients.id FROM oauth_clients WHERE oauth_clients.id = $1 AND owner_id = $2)
+    AND revoked_at IS NULL
+RETURNING id;
+
+-- name: RevokeOtherUserSessions :many
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+RETURNING id;
+
+-- name: RevokeUserSessions :many
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+RETURNING id;
\ No newline at end of file
@@ -0,0 +1,97 @@
+-- name: ListTimelineChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (user_id = sqlc.arg('user_id')
+       OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListMaterializedTimeline :many
+SELECT chirps.* FROM timeline_entries
+JOIN chirps ON chirps.id = timeline_entries.chirp_id
+WHERE timeline_entries.user_id = sqlc.arg('user_id')
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+       OR (timeline_entries.created_at, timeline_entries.chirp_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: IsTimelineMaterialized :one
+SELECT EXISTS (SELECT 1 FROM materialized_timelines WHERE user_id = $1);
+
+-- name: GetMaterializedTimeline :one
+SELECT * FROM materialized_timelines
+WHERE user_id = $1;
+
+-- name: LockTimelineFollows :exec
+-- Waits for unfollows by the user that are still in progress.
+SELECT 1 FROM follows
+WHERE follower_id = $1
+FOR UPDATE;
+
+-- name: LockTimelineAuthors :exec
+-- Waits for chirps being posted by the user or anyone they follow, and for
+-- follows by the user, all of which hold a key share lock on the users
+-- involved. The users are locked in order so that timelines materialized at
+-- the same time cannot deadlock.
+SELECT id FROM users
+WHERE id = sqlc.arg('user_id')
+   OR id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id'))
+ORDER BY id
+FOR UPDATE;
+
+-- name: MaterializeTimeline :exec
+-- Marks the timeline as kept and fills it with its newest chirps. It is one
+-- statement so that a failure cannot leave the timeline marked but empty.
+WITH recent AS (
+    SELECT id, created_at FROM chirps
+    WHERE deleted_at IS NULL
+      AND (user_id = sqlc.arg('user_id')
+           OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')))
+    ORDER BY created_at DESC, id DESC
+    LIMIT sqlc.arg('limit')
+), marked AS (
+    INSERT INTO materialized_timelines (user_id, created_at, complete_after)
+    SELECT sqlc.arg('user_id')::uuid, NOW(),
+           CASE WHEN COUNT(*) >= sqlc.arg('limit') THEN MIN(created_at) END
+    FROM recent
+    ON CONFLICT DO NOTHING
+    RETURNING user_id
+)
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT marked.user_id, recent.id, recent.created_at FROM marked, recent;
+
+-- name: FanOutChirp :exec
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT materialized_timelines.user_id, sqlc.arg('chirp_id')::uuid, sqlc.arg('created_at')::timestamp FROM materialized_timelines
+WHERE materialized_timelines.user_id = sqlc.arg('author_id')
+   OR materialized_timelines.user_id IN (SELECT follower_id FROM follows WHERE followee_id = sqlc.arg('author_id'))
+ON CONFLICT DO NOTHING;
+
+-- name: AddFolloweeToTimeline :exec
+-- Adds the followee's newest chirps. If that leaves older ones out, the
+-- timeline is only complete from the oldest one added on.
+WITH recent AS (
+    SELECT id, created_at FROM chirps
+    WHERE user_id = sqlc.arg('followee_id') AND deleted_at IS NULL
+    ORDER BY created_at DESC, id DESC
+    LIMIT sqlc.arg('limit')
+), cut_off AS (
+    UPDATE materialized_timelines
+    SET complete_after = GREATEST(complete_after, (SELECT MIN(created_at) FROM recent))
+    WHERE user_id = sqlc.arg('follower_id')
+      AND (SELECT COUNT(*) FROM recent) >= sqlc.arg('limit')
+)
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT materialized_timelines.user_id, recent.id, recent.created_at FROM materialized_timelines, recent
+WHERE materialized_timelines.user_id = sqlc.arg('follower_id')
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFolloweeFromTimeline :exec
+DELETE FROM timeline_entries
+USING chirps
+WHERE timeline_entries.chirp_id = chirps.id
+  AND timeline_entries.user_id = sqlc.arg('follower_id')
+  AND chirps.user_id = sqlc.arg('followee_id');
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, user_id, provider, subject, email)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2;
\ No newline at end of file
@@ -13,5 +13,82 @@ RETURNING *;
 SELECT * FROM users
 WHERE email = $1;
 
+-- name: GetUserByEmailFold :one
+-- Looks a user up by email ignoring case, oldest account first, for
+-- matching addresses that come from outside Chirpy.
+SELECT * FROM users
+WHERE LOWER(email) = LOWER($1)
+ORDER BY created_at
+LIMIT 1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RehashUserPassword :exec
+-- Only replaces the hash that was checked, so that a password changed in the
+-- meantime is not overwritten with the old one.
+UPDATE users
+SET hashed_password = sqlc.arg('hashed_password'), updated_at = NOW()
+WHERE id = sqlc.arg('id') AND hashed_password = sqlc.arg('old_hashed_password');
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableUserTOTP :execrows
+UPDATE users
+SET totp_enabled_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseUserTOTPCounter :execrows
+-- Records the time step of a TOTP code that was just accepted. It affects no
+-- rows if that step or a later one has been used before, so each code works
+-- only once.
+UPDATE users
+SET totp_last_counter = $2, updated_at = NOW()
+WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2);
+
+-- name: UpgradeUserToChirpyRed :one
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = NOW()
+WHERE id = $1 AND NOT is_chirpy_red
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1;
+
 -- name: DeleteAllUsers :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = $2,
+    hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Only a hash of each token is stored, so a leaked table cannot be used to
+-- log in. replaced_by holds the hash of the token that replaced it.
+CREATE TABLE refresh_tokens(
+    token_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMP,
+    replaced_by TEXT
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens(family_id);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
\ No newline at end of file
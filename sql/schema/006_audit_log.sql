@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE audit_log(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_id UUID NOT NULL,
+    details TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE audit_log;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts from before verification existed are trusted as they are, so
+-- that turning on REQUIRE_VERIFIED_EMAIL does not stop them from posting.
+UPDATE users
+SET email_verified_at = created_at;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN email_verified_at;
\ No newline at end of file
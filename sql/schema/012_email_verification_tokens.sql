@@ -0,0 +1,15 @@
+-- +goose Up
+-- created_at is compared with the time in Go for the resend cooldown, so
+-- it carries a time zone like expires_at.
+CREATE TABLE email_verification_tokens(
+    token_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens(user_id, created_at);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
\ No newline at end of file
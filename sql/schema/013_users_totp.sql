@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT,
+ADD COLUMN totp_enabled_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_secret;
\ No newline at end of file
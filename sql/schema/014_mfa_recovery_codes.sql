@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE mfa_recovery_codes(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX mfa_recovery_codes_user_id_idx ON mfa_recovery_codes(user_id);
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+DROP CONSTRAINT users_role_check;
\ No newline at end of file
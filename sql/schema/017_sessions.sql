@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE sessions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
+
+-- Every refresh token family so far was a login, so it becomes a session.
+INSERT INTO sessions (id, created_at, last_used_at, user_id, user_agent, ip, revoked_at)
+SELECT
+    family_id,
+    MIN(created_at),
+    MAX(updated_at),
+    user_id,
+    '',
+    '',
+    CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT refresh_tokens_family_id_fkey FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_family_id_fkey;
+
+DROP TABLE sessions;
\ No newline at end of file
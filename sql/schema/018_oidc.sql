@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE user_identities(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+
+CREATE TABLE oidc_login_states(
+    state_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
\ No newline at end of file
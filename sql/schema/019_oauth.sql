@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE oauth_clients(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    secret_hash TEXT,
+    redirect_uris TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL
+);
+
+CREATE INDEX oauth_clients_owner_id_idx ON oauth_clients(owner_id);
+
+CREATE TABLE oauth_authorization_codes(
+    code_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Apps that a user authorized show up among their sessions.
+ALTER TABLE sessions
+ADD COLUMN client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE sessions
+DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
\ No newline at end of file
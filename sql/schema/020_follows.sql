@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE follows(
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+-- The primary key only serves lookups of a single follow. Both lists are
+-- paged newest first with the other user's ID as the tie breaker, so each
+-- direction gets an index in exactly that order.
+CREATE INDEX follows_follower_id_created_at_idx ON follows(follower_id, created_at, followee_id);
+CREATE INDEX follows_followee_id_created_at_idx ON follows(followee_id, created_at, follower_id);
+
+-- +goose Down
+DROP TABLE follows;
\ No newline at end of file
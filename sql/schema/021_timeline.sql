@@ -0,0 +1,27 @@
+-- +goose Up
+-- Users whose home timeline is kept in timeline_entries instead of being
+-- assembled from follows on every read.
+-- Backfills copy a bounded number of chirps, so entries are only complete
+-- for chirps newer than complete_after; older ones are read from follows.
+-- It is NULL while every chirp of the timeline is kept.
+CREATE TABLE materialized_timelines(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    complete_after TIMESTAMP
+);
+
+-- created_at is copied from the chirp so a page can be read from the index
+-- alone.
+CREATE TABLE timeline_entries(
+    user_id UUID NOT NULL REFERENCES materialized_timelines(user_id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX timeline_entries_user_id_created_at_chirp_id_idx ON timeline_entries(user_id, created_at, chirp_id);
+CREATE INDEX timeline_entries_chirp_id_idx ON timeline_entries(chirp_id);
+
+-- +goose Down
+DROP TABLE timeline_entries;
+DROP TABLE materialized_timelines;
\ No newline at end of file
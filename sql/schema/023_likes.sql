@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE likes(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX likes_chirp_id_created_at_user_id_idx ON likes(chirp_id, created_at, user_id);
+CREATE INDEX likes_user_id_idx ON likes(user_id);
+
+-- +goose Down
+DROP TABLE likes;
\ No newline at end of file
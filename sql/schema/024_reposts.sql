@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN repost_of_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+ADD COLUMN quote_of_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD CONSTRAINT chirps_repost_or_quote_check CHECK (repost_of_id IS NULL OR quote_of_id IS NULL);
+
+-- A user can only repost a chirp once.
+CREATE UNIQUE INDEX chirps_user_id_repost_of_id_idx ON chirps(user_id, repost_of_id)
+WHERE repost_of_id IS NOT NULL;
+CREATE INDEX chirps_repost_of_id_idx ON chirps(repost_of_id);
+CREATE INDEX chirps_quote_of_id_idx ON chirps(quote_of_id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN quote_of_id,
+DROP COLUMN repost_of_id;
\ No newline at end of file
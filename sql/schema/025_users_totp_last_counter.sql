@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN totp_last_counter BIGINT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN totp_last_counter;
\ No newline at end of file